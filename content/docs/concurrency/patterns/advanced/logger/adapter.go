@@ -0,0 +1,180 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// EntryLogger declares behavior for logging leveled entries. Both Logger
+// and Fanout implement it so the adapters work with either.
+type EntryLogger interface {
+	Enabled(lvl Level) bool
+	Log(e Entry)
+}
+
+// =============================================================================
+
+// Write implements the io.Writer interface so the logger can back a
+// standard library log.Logger. Every call is one line, written as is,
+// with the same drop behavior as Println. It never fails.
+//
+//	std := log.New(l, "prefix ", log.LstdFlags)
+func (l *Logger) Write(p []byte) (int, error) {
+	l.Println(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// Write implements the io.Writer interface by writing the line to every
+// sink of the Fanout.
+func (f *Fanout) Write(p []byte) (int, error) {
+	f.Println(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// levelWriter logs every write as an entry at a fixed level.
+type levelWriter struct {
+	l   EntryLogger
+	lvl Level
+}
+
+// LevelWriter returns an io.Writer that logs every write as a message at
+// the specified level, so lines from a standard library log.Logger go
+// through the encoder like any other leveled message.
+//
+//	std := log.New(logger.LevelWriter(l, logger.LevelWarn), "", 0)
+func LevelWriter(l EntryLogger, lvl Level) io.Writer {
+	return levelWriter{l: l, lvl: lvl}
+}
+
+// Write implements the io.Writer interface.
+func (w levelWriter) Write(p []byte) (int, error) {
+	if w.l.Enabled(w.lvl) {
+		w.l.Log(Entry{
+			Time:    time.Now(),
+			Level:   w.lvl,
+			Message: strings.TrimSuffix(string(p), "\n"),
+		})
+	}
+	return len(p), nil
+}
+
+// =============================================================================
+
+// Handler is a slog.Handler that logs records through a Logger or Fanout,
+// giving code written against log/slog the drop on backpressure behavior.
+// Attributes become fields and groups qualify the field keys with dots.
+//
+//	log := slog.New(logger.NewHandler(l))
+type Handler struct {
+	l      EntryLogger
+	fields []interface{}
+	prefix string
+}
+
+// NewHandler returns a Handler for the specified logger.
+func NewHandler(l EntryLogger) *Handler {
+	return &Handler{l: l}
+}
+
+// Enabled implements the slog.Handler interface.
+func (h *Handler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return h.l.Enabled(fromSlog(lvl))
+}
+
+// Handle implements the slog.Handler interface.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	fields := make([]interface{}, len(h.fields), len(h.fields)+2*r.NumAttrs())
+	copy(fields, h.fields)
+
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+
+	// A zero time is left alone so the encoders leave it out.
+	h.l.Log(Entry{
+		Time:    r.Time,
+		Level:   fromSlog(r.Level),
+		Message: r.Message,
+		Fields:  fields,
+	})
+
+	return nil
+}
+
+// WithAttrs implements the slog.Handler interface.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.fields = make([]interface{}, len(h.fields), len(h.fields)+2*len(attrs))
+	copy(h2.fields, h.fields)
+	for _, a := range attrs {
+		h2.fields = appendAttr(h2.fields, h.prefix, a)
+	}
+
+	return &h2
+}
+
+// WithGroup implements the slog.Handler interface.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// appendAttr adds the attribute as a key/value pair, flattening groups
+// into dotted keys.
+func appendAttr(fields []interface{}, prefix string, a slog.Attr) []interface{} {
+	a.Value = a.Value.Resolve()
+
+	// Empty attributes are ignored per the slog.Handler rules.
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+
+		// A group without a key is inlined.
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range attrs {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+
+	return append(fields, prefix+a.Key, a.Value.Any())
+}
+
+// fromSlog maps a slog level onto the closest logger level.
+func fromSlog(lvl slog.Level) Level {
+	switch {
+	case lvl < slog.LevelInfo:
+		return LevelDebug
+	case lvl < slog.LevelWarn:
+		return LevelInfo
+	case lvl < slog.LevelError:
+		return LevelWarn
+	}
+	return LevelError
+}
@@ -0,0 +1,139 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate the adapters for log/slog and the standard library
+// log package.
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+)
+
+// newAdapted returns a logger writing JSON to a device that never drops
+// a line.
+func newAdapted(lvl Level) (*Logger, *Device) {
+	d := NewDevice(nil)
+	l := New(d, 100, WithEncoder(JSONEncoder{}), WithLevel(lvl), WithOverflow(Block()))
+	return l, d
+}
+
+// entries flushes the logger and decodes every line the device received,
+// turning dotted keys back into the nested groups they came from.
+func entries(t *testing.T, l *Logger, d *Device) []map[string]any {
+	t.Helper()
+
+	if err := l.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []map[string]any
+	for _, line := range d.Lines() {
+		var flat map[string]any
+		if err := json.Unmarshal([]byte(line), &flat); err != nil {
+			t.Fatalf("\t%s\tShould write JSON : %q : %v", failed, line, err)
+		}
+
+		m := make(map[string]any)
+		for k, v := range flat {
+			keys := strings.Split(k, ".")
+			group := m
+			for _, g := range keys[:len(keys)-1] {
+				sub, ok := group[g].(map[string]any)
+				if !ok {
+					sub = make(map[string]any)
+					group[g] = sub
+				}
+				group = sub
+			}
+			group[keys[len(keys)-1]] = v
+		}
+		ms = append(ms, m)
+	}
+
+	return ms
+}
+
+// TestHandler validates the slog Handler against the conformance tests
+// of the standard library.
+func TestHandler(t *testing.T) {
+	t.Log("Given the need to log through log/slog.")
+	{
+		t.Log("\tWhen running the slogtest conformance tests.")
+		{
+			l, d := newAdapted(LevelDebug)
+			defer l.Close(context.Background())
+
+			results := func() []map[string]any {
+				return entries(t, l, d)
+			}
+
+			if err := slogtest.TestHandler(NewHandler(l), results); err != nil {
+				t.Fatalf("\t%s\tShould pass : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould pass.", succeed)
+		}
+
+		t.Log("\tWhen logging below the logger's level.")
+		{
+			l, d := newAdapted(LevelWarn)
+			defer l.Close(context.Background())
+
+			sl := slog.New(NewHandler(l))
+			sl.Info("skipped")
+			sl.Warn("kept", "n", 1)
+
+			got := entries(t, l, d)
+			if len(got) != 1 || got[0]["msg"] != "kept" || got[0]["level"] != "warn" {
+				t.Fatalf("\t%s\tShould only log the warning : %v", failed, got)
+			}
+			t.Logf("\t%s\tShould only log the warning.", succeed)
+		}
+	}
+}
+
+// TestStdLog validates a standard library log.Logger can write through
+// the logger, as is or as leveled messages.
+func TestStdLog(t *testing.T) {
+	t.Log("Given the need to log through the standard library log package.")
+	{
+		t.Log("\tWhen writing through Logger.Write.")
+		{
+			d := NewDevice(nil)
+			l := New(d, 100, WithOverflow(Block()))
+
+			std := log.New(l, "app: ", 0)
+			std.Println("started")
+			std.Printf("port %d", 8080)
+			l.Close(context.Background())
+
+			got := d.Lines()
+			if strings.Join(got, ",") != "app: started,app: port 8080" {
+				t.Fatalf("\t%s\tShould write each call as one line : %q", failed, got)
+			}
+			t.Logf("\t%s\tShould write each call as one line.", succeed)
+		}
+
+		t.Log("\tWhen writing through a LevelWriter.")
+		{
+			l, d := newAdapted(LevelInfo)
+			defer l.Close(context.Background())
+
+			log.New(LevelWriter(l, LevelWarn), "", 0).Print("disk almost full")
+			log.New(LevelWriter(l, LevelDebug), "", 0).Print("skipped")
+
+			got := entries(t, l, d)
+			if len(got) != 1 || got[0]["msg"] != "disk almost full" || got[0]["level"] != "warn" || got[0]["time"] == nil {
+				t.Fatalf("\t%s\tShould log a warning and skip the debug message : %v", failed, got)
+			}
+			t.Logf("\t%s\tShould log a warning and skip the debug message.", succeed)
+		}
+	}
+}
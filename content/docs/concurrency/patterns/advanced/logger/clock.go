@@ -0,0 +1,212 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock declares behavior for telling time and waiting on it. The logger
+// uses the real clock unless told otherwise, tests use a FakeClock so they
+// can move time forward without sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer declares the behavior of a time.Timer that a Clock hands out.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// WithClock sets the clock the logger tells time with.
+func WithClock(c Clock) Option {
+	return func(l *Logger) {
+		l.clock = c
+	}
+}
+
+// =============================================================================
+
+// realClock tells time using the time package.
+type realClock struct{}
+
+// Now implements the Clock interface.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer implements the Clock interface.
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{t: time.NewTimer(d)}
+}
+
+// realTimer adapts a time.Timer to the Timer interface.
+type realTimer struct {
+	t *time.Timer
+}
+
+// C implements the Timer interface.
+func (r realTimer) C() <-chan time.Time {
+	return r.t.C
+}
+
+// Stop implements the Timer interface.
+func (r realTimer) Stop() bool {
+	return r.t.Stop()
+}
+
+// Reset implements the Timer interface.
+func (r realTimer) Reset(d time.Duration) bool {
+	return r.t.Reset(d)
+}
+
+// =============================================================================
+
+// FakeClock is a Clock that only moves when Advance is called. Timers fire
+// once the clock has been advanced to or past their deadline.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock set to the specified time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now implements the Clock interface.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer implements the Clock interface.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := fakeTimer{
+		clock: c,
+		ch:    make(chan time.Time, 1),
+	}
+	t.schedule(d)
+
+	return &t
+}
+
+// Advance moves the clock forward and fires every timer that is due.
+// Timers that are no longer active are let go until they are reset.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		t.fire()
+		if t.active {
+			timers = append(timers, t)
+			continue
+		}
+		t.listed = false
+	}
+	c.timers = timers
+}
+
+// Timers returns the number of timers waiting to fire. Tests use it to know
+// a goroutine is waiting on the clock before advancing it.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int
+	for _, t := range c.timers {
+		if t.active {
+			n++
+		}
+	}
+	return n
+}
+
+// fakeTimer is a Timer driven by a FakeClock. The clock's mutex protects
+// its fields.
+type fakeTimer struct {
+	clock  *FakeClock
+	ch     chan time.Time
+	when   time.Time
+	active bool
+	listed bool
+}
+
+// C implements the Timer interface.
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+// Stop implements the Timer interface.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.active
+	t.active = false
+	t.drain()
+	return active
+}
+
+// Reset implements the Timer interface.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.active
+	t.schedule(d)
+	return active
+}
+
+// schedule sets the deadline of the timer, firing it right away if the
+// deadline has already been reached. Like a time.Timer, a value left on
+// the channel from before is thrown away.
+func (t *fakeTimer) schedule(d time.Duration) {
+	t.drain()
+	t.when = t.clock.now.Add(d)
+	t.active = true
+
+	if !t.listed {
+		t.clock.timers = append(t.clock.timers, t)
+		t.listed = true
+	}
+
+	t.fire()
+}
+
+// fire sends the time on the channel if the timer is due.
+func (t *fakeTimer) fire() {
+	if !t.active || t.when.After(t.clock.now) {
+		return
+	}
+
+	t.active = false
+	select {
+	case t.ch <- t.clock.now:
+	default:
+	}
+}
+
+// drain throws away a value that fired but was never received.
+func (t *fakeTimer) drain() {
+	select {
+	case <-t.ch:
+	default:
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package logger
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Device is an io.Writer that can be scripted to misbehave, so tests can
+// exercise a logger against a stalled, failing, partial or slow device
+// without real sleeps. Periods of time are measured on the Device's clock,
+// which should be the FakeClock given to the logger.
+//
+// Faults are applied in this order: a stall holds the write until it's
+// lifted, a slow write waits on the clock, then a failure or a partial
+// write decides what the write returns.
+type Device struct {
+	clock Clock
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	writes  int
+	stall   chan struct{} // Closed when the stall is lifted, nil when not stalled.
+	slow    []time.Duration
+	fail    []error
+	partial []int
+	parked  chan struct{} // Signaled every time a write starts waiting.
+}
+
+// NewDevice returns a Device using the specified clock. A nil clock uses
+// the real one.
+func NewDevice(clock Clock) *Device {
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	return &Device{
+		clock:  clock,
+		parked: make(chan struct{}, 1),
+	}
+}
+
+// Stall makes every write wait until Resume is called.
+func (d *Device) Stall() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stall == nil {
+		d.stall = make(chan struct{})
+	}
+}
+
+// Resume lifts a stall and lets the waiting writes through.
+func (d *Device) Resume() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stall != nil {
+		close(d.stall)
+		d.stall = nil
+	}
+}
+
+// StallFor makes every write wait until the clock has moved forward by
+// the specified duration.
+func (d *Device) StallFor(dur time.Duration) {
+	d.Stall()
+
+	t := d.clock.NewTimer(dur)
+	go func() {
+		<-t.C()
+		d.Resume()
+	}()
+}
+
+// SlowFor makes the next n writes each take the specified duration on
+// the clock.
+func (d *Device) SlowFor(n int, dur time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		d.slow = append(d.slow, dur)
+	}
+}
+
+// Fail makes the next n writes fail with the specified error without
+// writing anything.
+func (d *Device) Fail(n int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		d.fail = append(d.fail, err)
+	}
+}
+
+// Partial makes the next n writes only accept up to max bytes and return
+// io.ErrShortWrite when they were given more.
+func (d *Device) Partial(n int, max int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		d.partial = append(d.partial, max)
+	}
+}
+
+// Parked returns a channel that is signaled when a write starts waiting
+// on a stall or on the clock. Tests use it to know the writer goroutine is
+// stuck before making more calls.
+func (d *Device) Parked() <-chan struct{} {
+	return d.parked
+}
+
+// Write implements the io.Writer interface.
+func (d *Device) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	stall := d.stall
+	var slow time.Duration
+	if len(d.slow) > 0 {
+		slow, d.slow = d.slow[0], d.slow[1:]
+	}
+	d.mu.Unlock()
+
+	if stall != nil {
+		d.park()
+		<-stall
+	}
+
+	if slow > 0 {
+		t := d.clock.NewTimer(slow)
+		d.park()
+		<-t.C()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.writes++
+
+	if len(d.fail) > 0 {
+		err := d.fail[0]
+		d.fail = d.fail[1:]
+		return 0, err
+	}
+
+	if len(d.partial) > 0 {
+		max := d.partial[0]
+		d.partial = d.partial[1:]
+		if max < len(p) {
+			d.buf.Write(p[:max])
+			return max, io.ErrShortWrite
+		}
+	}
+
+	d.buf.Write(p)
+	return len(p), nil
+}
+
+// park signals that a write is waiting without blocking the write.
+func (d *Device) park() {
+	select {
+	case d.parked <- struct{}{}:
+	default:
+	}
+}
+
+// Writes returns the number of calls to Write that got past the stalls
+// and the clock.
+func (d *Device) Writes() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writes
+}
+
+// Lines returns every complete line written to the device.
+func (d *Device) Lines() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := strings.TrimSuffix(d.buf.String(), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,181 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry represents a single leveled log message and its fields. Fields
+// are stored as alternating keys and values. The encoders leave out the
+// time when it's zero.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  []interface{}
+}
+
+// Encoder declares behavior for turning an entry into a line of text.
+type Encoder interface {
+	Encode(e Entry) string
+}
+
+// timeFormat is used by every encoder to write the time of the entry.
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// fields calls f for every key/value pair in the entry. A key that is not
+// a string is converted to one, and a key without a value gets a value of
+// "MISSING" so the mistake shows up in the log.
+func fields(kv []interface{}, f func(k string, v interface{})) {
+	for i := 0; i < len(kv); i += 2 {
+		k, ok := kv[i].(string)
+		if !ok {
+			k = fmt.Sprint(kv[i])
+		}
+
+		var v interface{} = "MISSING"
+		if i+1 < len(kv) {
+			v = kv[i+1]
+		}
+
+		f(k, v)
+	}
+}
+
+// =============================================================================
+
+// TextEncoder writes entries as plain text meant for people to read.
+//
+//	2009-11-10T23:00:00.000000Z INFO  user logged in user=bill id=42
+type TextEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (TextEncoder) Encode(e Entry) string {
+	var b strings.Builder
+	if !e.Time.IsZero() {
+		b.WriteString(e.Time.Format(timeFormat))
+		b.WriteByte(' ')
+	}
+	fmt.Fprintf(&b, "%-5s", e.Level)
+	b.WriteByte(' ')
+	b.WriteString(e.Message)
+
+	fields(e.Fields, func(k string, v interface{}) {
+		b.WriteByte(' ')
+		b.WriteString(k)
+		b.WriteByte('=')
+		fmt.Fprint(&b, v)
+	})
+
+	return b.String()
+}
+
+// =============================================================================
+
+// LogfmtEncoder writes entries as logfmt key/value pairs.
+//
+//	time=2009-11-10T23:00:00.000000Z level=info msg="user logged in" user=bill
+type LogfmtEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (LogfmtEncoder) Encode(e Entry) string {
+	var b strings.Builder
+	if !e.Time.IsZero() {
+		b.WriteString("time=")
+		b.WriteString(e.Time.Format(timeFormat))
+		b.WriteByte(' ')
+	}
+	b.WriteString("level=")
+	b.WriteString(strings.ToLower(e.Level.String()))
+	b.WriteString(" msg=")
+	b.WriteString(logfmtValue(e.Message))
+
+	fields(e.Fields, func(k string, v interface{}) {
+		b.WriteByte(' ')
+		b.WriteString(logfmtKey(k))
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(fmt.Sprint(v)))
+	})
+
+	return b.String()
+}
+
+// logfmtKey removes the characters that can't be part of a key.
+func logfmtKey(k string) string {
+	k = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, k)
+
+	if k == "" {
+		return "_"
+	}
+	return k
+}
+
+// logfmtValue quotes the value when it contains spaces, quotes or an
+// equal sign.
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " =\"\t\r\n") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// =============================================================================
+
+// JSONEncoder writes each entry as a JSON object on a single line.
+//
+//	{"time":"2009-11-10T23:00:00.000000Z","level":"info","msg":"user logged in","user":"bill"}
+type JSONEncoder struct{}
+
+// Encode implements the Encoder interface.
+func (JSONEncoder) Encode(e Entry) string {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	if !e.Time.IsZero() {
+		b.WriteString(`"time":`)
+		writeJSON(&b, e.Time.Format(timeFormat))
+		b.WriteByte(',')
+	}
+	b.WriteString(`"level":`)
+	writeJSON(&b, strings.ToLower(e.Level.String()))
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, e.Message)
+
+	fields(e.Fields, func(k string, v interface{}) {
+		b.WriteByte(',')
+		writeJSON(&b, k)
+		b.WriteByte(':')
+
+		// Errors don't marshal into anything useful.
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		writeJSON(&b, v)
+	})
+
+	b.WriteByte('}')
+	return b.String()
+}
+
+// writeJSON writes the value as JSON. A value that can't be marshaled is
+// written as a string instead so the entry is never lost.
+func writeJSON(b *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
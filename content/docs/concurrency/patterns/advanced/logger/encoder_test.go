@@ -0,0 +1,111 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate how each encoder writes entries, quotes values and
+// handles odd fields.
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// unmarshalable is a value encoding/json can't marshal.
+type unmarshalable struct {
+	C chan int
+}
+
+// String makes the value readable when it's written as a string.
+func (unmarshalable) String() string {
+	return "a channel"
+}
+
+// encoderTests are the entries every encoder is tested with.
+var encoderTests = []struct {
+	name  string
+	entry Entry
+}{
+	{"a message with fields", Entry{Time: start, Level: LevelInfo, Message: "user logged in", Fields: []interface{}{"user", "bill", "id", 42}}},
+	{"no time", Entry{Level: LevelWarn, Message: "disk", Fields: []interface{}{"free", 0.5}}},
+	{"a value with spaces", Entry{Level: LevelInfo, Message: "m", Fields: []interface{}{"path", "/my files/a b"}}},
+	{"a value with quotes", Entry{Level: LevelInfo, Message: "m", Fields: []interface{}{"q", `say "hi"`}}},
+	{"a value with a newline", Entry{Level: LevelInfo, Message: "line 1\nline 2", Fields: []interface{}{"v", "a\nb"}}},
+	{"an empty value and an equal sign", Entry{Level: LevelDebug, Message: "", Fields: []interface{}{"empty", "", "eq", "a=b"}}},
+	{"an odd number of fields", Entry{Level: LevelError, Message: "m", Fields: []interface{}{"k", "v", "lonely"}}},
+	{"a key that isn't a string", Entry{Level: LevelInfo, Message: "m", Fields: []interface{}{42, "v"}}},
+	{"a key with spaces and quotes", Entry{Level: LevelInfo, Message: "m", Fields: []interface{}{`my "key"=`, "v", "", "w"}}},
+	{"an error", Entry{Level: LevelError, Message: "m", Fields: []interface{}{"err", errors.New("disk full")}}},
+	{"a value json can't marshal", Entry{Level: LevelInfo, Message: "m", Fields: []interface{}{"v", unmarshalable{}}}},
+}
+
+// TestEncoders validates each encoder writes the entries as expected.
+func TestEncoders(t *testing.T) {
+	encoders := []struct {
+		name string
+		enc  Encoder
+		want []string
+	}{
+		{"text", TextEncoder{}, []string{
+			"2009-11-10T23:00:00.000000Z INFO  user logged in user=bill id=42",
+			"WARN  disk free=0.5",
+			"INFO  m path=/my files/a b",
+			`INFO  m q=say "hi"`,
+			"INFO  line 1\nline 2 v=a\nb",
+			"DEBUG  empty= eq=a=b",
+			"ERROR m k=v lonely=MISSING",
+			"INFO  m 42=v",
+			`INFO  m my "key"==v =w`,
+			"ERROR m err=disk full",
+			"INFO  m v=a channel",
+		}},
+		{"logfmt", LogfmtEncoder{}, []string{
+			`time=2009-11-10T23:00:00.000000Z level=info msg="user logged in" user=bill id=42`,
+			"level=warn msg=disk free=0.5",
+			`level=info msg=m path="/my files/a b"`,
+			`level=info msg=m q="say \"hi\""`,
+			`level=info msg="line 1\nline 2" v="a\nb"`,
+			`level=debug msg="" empty="" eq="a=b"`,
+			"level=error msg=m k=v lonely=MISSING",
+			"level=info msg=m 42=v",
+			"level=info msg=m my__key__=v _=w",
+			`level=error msg=m err="disk full"`,
+			`level=info msg=m v="a channel"`,
+		}},
+		{"json", JSONEncoder{}, []string{
+			`{"time":"2009-11-10T23:00:00.000000Z","level":"info","msg":"user logged in","user":"bill","id":42}`,
+			`{"level":"warn","msg":"disk","free":0.5}`,
+			`{"level":"info","msg":"m","path":"/my files/a b"}`,
+			`{"level":"info","msg":"m","q":"say \"hi\""}`,
+			`{"level":"info","msg":"line 1\nline 2","v":"a\nb"}`,
+			`{"level":"debug","msg":"","empty":"","eq":"a=b"}`,
+			`{"level":"error","msg":"m","k":"v","lonely":"MISSING"}`,
+			`{"level":"info","msg":"m","42":"v"}`,
+			`{"level":"info","msg":"m","my \"key\"=":"v","":"w"}`,
+			`{"level":"error","msg":"m","err":"disk full"}`,
+			`{"level":"info","msg":"m","v":"a channel"}`,
+		}},
+	}
+
+	t.Log("Given the need to encode entries as lines.")
+	{
+		for _, e := range encoders {
+			for i, tt := range encoderTests {
+				t.Logf("\tTest: %d\tWhen encoding %s as %s.", i, tt.name, e.name)
+				{
+					got := e.enc.Encode(tt.entry)
+					if got != e.want[i] {
+						t.Fatalf("\t%s\tShould encode as %q : %q", failed, e.want[i], got)
+					}
+					t.Logf("\t%s\tShould encode as %q.", succeed, e.want[i])
+
+					if e.name == "json" && !json.Valid([]byte(got)) {
+						t.Fatalf("\t%s\tShould be valid JSON.", failed)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package logger
+
+import (
+	"context"
+)
+
+// Fanout sends every message to a set of loggers. Each logger acts as an
+// independent sink with its own buffer, overflow policy, level filter and
+// drop counters. When one sink's device stalls, only that sink drops
+// messages and the others keep flowing.
+//
+// A sink using the Block or BlockTimeout policy makes the caller wait,
+// which holds up the sinks after it as well.
+type Fanout struct {
+	sinks []*Logger
+	clock Clock
+}
+
+// NewFanout returns a Fanout for the specified sinks. The Fanout owns the
+// sinks from here on and closes them when it's closed. Messages are timed
+// with the clock of the first sink.
+func NewFanout(sinks ...*Logger) *Fanout {
+	var clock Clock = realClock{}
+	if len(sinks) > 0 {
+		clock = sinks[0].clock
+	}
+
+	return &Fanout{
+		sinks: sinks,
+		clock: clock,
+	}
+}
+
+// Println prints the line to every sink.
+func (f *Fanout) Println(v string) {
+	for _, s := range f.sinks {
+		s.Println(v)
+	}
+}
+
+// Debug logs a message with key/value fields at the debug level.
+func (f *Fanout) Debug(msg string, kv ...interface{}) {
+	f.log(LevelDebug, msg, kv)
+}
+
+// Info logs a message with key/value fields at the info level.
+func (f *Fanout) Info(msg string, kv ...interface{}) {
+	f.log(LevelInfo, msg, kv)
+}
+
+// Warn logs a message with key/value fields at the warn level.
+func (f *Fanout) Warn(msg string, kv ...interface{}) {
+	f.log(LevelWarn, msg, kv)
+}
+
+// Error logs a message with key/value fields at the error level.
+func (f *Fanout) Error(msg string, kv ...interface{}) {
+	f.log(LevelError, msg, kv)
+}
+
+// Enabled reports whether any sink will log messages at the level.
+func (f *Fanout) Enabled(lvl Level) bool {
+	for _, s := range f.sinks {
+		if s.Enabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+// log builds the entry once so every sink sees the same time, then lets
+// each sink filter and encode it.
+func (f *Fanout) log(lvl Level, msg string, kv []interface{}) {
+	if !f.Enabled(lvl) {
+		return
+	}
+
+	f.Log(Entry{
+		Time:    f.clock.Now(),
+		Level:   lvl,
+		Message: msg,
+		Fields:  kv,
+	})
+}
+
+// Log hands the entry to every sink to filter and encode.
+func (f *Fanout) Log(e Entry) {
+	for _, s := range f.sinks {
+		s.Log(e)
+	}
+}
+
+// Flush blocks until every sink has written what was queued before the
+// call, or the context is done. The sinks are flushed concurrently so a
+// stalled sink doesn't hold up the others.
+func (f *Fanout) Flush(ctx context.Context) error {
+	errs := make(chan error, len(f.sinks))
+	for _, s := range f.sinks {
+		go func(s *Logger) {
+			errs <- s.Flush(ctx)
+		}(s)
+	}
+
+	var first error
+	for range f.sinks {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// Close closes every sink concurrently and returns the total number of
+// lines abandoned by sinks that didn't drain before the context was done.
+func (f *Fanout) Close(ctx context.Context) (int, error) {
+	type result struct {
+		abandoned int
+		err       error
+	}
+
+	results := make(chan result, len(f.sinks))
+	for _, s := range f.sinks {
+		go func(s *Logger) {
+			n, err := s.Close(ctx)
+			results <- result{n, err}
+		}(s)
+	}
+
+	var abandoned int
+	var first error
+	for range f.sinks {
+		r := <-results
+		abandoned += r.abandoned
+		if r.err != nil && first == nil {
+			first = r.err
+		}
+	}
+
+	return abandoned, first
+}
+
+// Healthy reports whether every sink is keeping up.
+func (f *Fanout) Healthy() bool {
+	for _, s := range f.sinks {
+		if !s.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// Stats returns the stats for every sink in the order they were given.
+func (f *Fanout) Stats() []Stats {
+	stats := make([]Stats, len(f.sinks))
+	for i, s := range f.sinks {
+		stats[i] = s.Stats()
+	}
+	return stats
+}
@@ -0,0 +1,77 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate a stalled sink doesn't hold up the others.
+package logger
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestFanout validates a stalled sink only drops its own lines while the
+// other sink keeps writing.
+func TestFanout(t *testing.T) {
+	t.Log("Given the need to log to more than one sink.")
+	{
+		for _, bk := range backends {
+			t.Logf("\tWhen logging 10 messages with one of two %s sinks stuck.", bk.name)
+			{
+				clock := NewFakeClock(start)
+				stuck := NewDevice(clock)
+				stuck.Stall()
+				healthy := NewDevice(clock)
+
+				opts := append([]Option{WithClock(clock)}, bk.opts...)
+				s1 := New(stuck, 4, opts...)
+				s2 := New(healthy, 4, opts...)
+				f := NewFanout(s1, s2)
+
+				f.Println("0")
+				<-stuck.Parked()
+
+				for i := 1; i <= 10; i++ {
+					f.Info("message", "n", i)
+
+					// Let the healthy sink catch up so it never drops.
+					if err := s2.Flush(context.Background()); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				got := healthy.Lines()
+				if len(got) != 11 {
+					t.Fatalf("\t%s\tShould write every line to the healthy sink : %q", failed, got)
+				}
+				for i, line := range got[1:] {
+					want := "2009-11-10T23:00:00.000000Z INFO  message n=" + strconv.Itoa(i+1)
+					if line != want {
+						t.Fatalf("\t%s\tShould write %q timed by the clock : %q", failed, want, line)
+					}
+				}
+				t.Logf("\t%s\tShould write every line to the healthy sink, timed by the clock.", succeed)
+
+				stats := f.Stats()
+				if stats[0].Dropped != 6 || stats[1].Dropped != 0 {
+					t.Fatalf("\t%s\tShould only drop lines on the stuck sink : %+v", failed, stats)
+				}
+				t.Logf("\t%s\tShould only drop lines on the stuck sink.", succeed)
+
+				stuck.Resume()
+				if n, err := f.Close(context.Background()); n != 0 || err != nil {
+					t.Fatalf("\t%s\tShould close every sink : %d, %v", failed, n, err)
+				}
+
+				checkStats(t, s1.Stats(), Stats{Accepted: 5, Dropped: 6, Written: 5})
+				if lines := stuck.Lines(); len(lines) != 6 || !strings.Contains(lines[1], "6 messages dropped") {
+					t.Fatalf("\t%s\tShould write what fit once the stuck sink recovers : %q", failed, lines)
+				}
+				t.Logf("\t%s\tShould write what fit once the stuck sink recovers.", succeed)
+			}
+		}
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// rotateFormat is appended to the path of a rotated file so the rotated
+// files sort in the order they were rotated.
+const rotateFormat = "20060102T150405.000000"
+
+// FileConfig provides the settings for a File sink.
+type FileConfig struct {
+	Path     string        // Path of the file being written to.
+	MaxSize  int64         // Rotate once the file grows past this many bytes, 0 disables.
+	MaxAge   time.Duration // Rotate once the file has been written to this long, 0 disables.
+	Keep     int           // Number of rotated files to keep, 0 keeps them all.
+	Compress bool          // Gzip rotated files in the background.
+}
+
+// handle is the part of an open file the File sink uses, so tests can put
+// a Device in its place.
+type handle interface {
+	io.WriteCloser
+	Stat() (os.FileInfo, error)
+}
+
+// openFile creates the directories and opens the file for appending.
+func openFile(path string) (handle, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+// File is an io.Writer that writes to a file and rotates it by size and
+// by age. It's meant to be the device for a Logger, which means only the
+// writer goroutine ever waits on it.
+//
+// The file is reopened when the process receives SIGHUP, so logrotate can
+// move the file out of the way and signal us to start a new one. When the
+// file can't be opened, after a rotation say because the disk is full or
+// we ran out of file descriptors, every write tries again until it works.
+type File struct {
+	cfg      FileConfig
+	openFile func(path string) (handle, error)
+
+	mu     sync.Mutex
+	f      handle // Nil when the last open failed or the sink is closed.
+	closed bool
+	size   int64
+	opened time.Time
+
+	sighup   chan os.Signal
+	compress chan struct{}
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewFile opens the file at the configured path for appending and starts
+// the goroutines handling SIGHUP and compression.
+func NewFile(cfg FileConfig) (*File, error) {
+	f := File{
+		cfg:      cfg,
+		openFile: openFile,
+		sighup:   make(chan os.Signal, 1),
+		compress: make(chan struct{}, 1),
+		shutdown: make(chan struct{}),
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	signal.Notify(f.sighup, syscall.SIGHUP)
+
+	f.wg.Add(2)
+
+	// Reopen the file every time we are asked to.
+	go func() {
+		defer f.wg.Done()
+		for {
+			select {
+			case <-f.sighup:
+				if err := f.Reopen(); err != nil {
+					log.Println("ERROR: logger: reopening", f.cfg.Path, err)
+				}
+			case <-f.shutdown:
+				return
+			}
+		}
+	}()
+
+	// Compress and prune the rotated files off the write path.
+	go func() {
+		defer f.wg.Done()
+		for {
+			select {
+			case <-f.compress:
+				f.cleanup()
+			case <-f.shutdown:
+				return
+			}
+		}
+	}()
+
+	return &f, nil
+}
+
+// Write implements the io.Writer interface. The file is rotated first if
+// the write would take it past its size or it's too old. A write the disk
+// only partly accepts is ended with a newline so the next write starts on
+// a line of its own.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	if f.f == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.due(len(p)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	var written int
+	for written < len(p) {
+		n, err := f.f.Write(p[written:])
+		written += n
+		f.size += int64(n)
+
+		if err != nil {
+			if written > 0 && p[written-1] != '\n' {
+				n, _ := f.f.Write([]byte{'\n'})
+				f.size += int64(n)
+			}
+			return written, err
+		}
+
+		if n == 0 {
+			return written, io.ErrShortWrite
+		}
+	}
+
+	return written, nil
+}
+
+// Rotate moves the current file out of the way and starts a new one.
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+
+	return f.rotate()
+}
+
+// Reopen closes and reopens the file at the configured path, for when
+// the file has been moved by someone else.
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+
+	if f.f != nil {
+		if err := f.f.Close(); err != nil {
+			log.Println("ERROR: logger: closing", f.cfg.Path, err)
+		}
+		f.f = nil
+	}
+
+	return f.open()
+}
+
+// Close stops the background goroutines and closes the file. Closing it
+// again does nothing.
+func (f *File) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	f.mu.Unlock()
+
+	signal.Stop(f.sighup)
+	close(f.shutdown)
+	f.wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.f == nil {
+		return nil
+	}
+
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
+
+// due reports whether the file needs to be rotated before writing n bytes.
+func (f *File) due(n int) bool {
+	if f.cfg.MaxSize > 0 && f.size > 0 && f.size+int64(n) > f.cfg.MaxSize {
+		return true
+	}
+
+	if f.cfg.MaxAge > 0 && time.Since(f.opened) >= f.cfg.MaxAge {
+		return true
+	}
+
+	return false
+}
+
+// open opens the file for appending and picks up its current size.
+func (f *File) open() error {
+	file, err := f.openFile(f.cfg.Path)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.f = file
+	f.size = info.Size()
+	f.opened = time.Now()
+
+	return nil
+}
+
+// rotate renames the current file with the time of the rotation, opens a
+// new one and signals the background goroutine to compress and prune.
+func (f *File) rotate() error {
+	if f.f != nil {
+		if err := f.f.Close(); err != nil {
+			log.Println("ERROR: logger: closing", f.cfg.Path, err)
+		}
+		f.f = nil
+	}
+
+	name := f.cfg.Path + "." + time.Now().Format(rotateFormat)
+	if err := os.Rename(f.cfg.Path, name); err != nil && !os.IsNotExist(err) {
+		log.Println("ERROR: logger: rotating", f.cfg.Path, err)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	select {
+	case f.compress <- struct{}{}:
+	default:
+		// A cleanup is already pending and will pick this file up.
+	}
+
+	return nil
+}
+
+// rotated returns the rotated files, oldest first.
+func (f *File) rotated() []string {
+	matches, err := filepath.Glob(f.cfg.Path + ".*")
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	prefix := len(f.cfg.Path) + 1
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(m[prefix:], ".gz")
+		if _, err := time.Parse(rotateFormat, stamp); err == nil {
+			files = append(files, m)
+		}
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// cleanup compresses the rotated files that are not compressed yet and
+// removes the oldest ones past the number we keep.
+func (f *File) cleanup() {
+	files := f.rotated()
+
+	if f.cfg.Keep > 0 && len(files) > f.cfg.Keep {
+		for _, name := range files[:len(files)-f.cfg.Keep] {
+			if err := os.Remove(name); err != nil {
+				log.Println("ERROR: logger: removing", name, err)
+			}
+		}
+		files = files[len(files)-f.cfg.Keep:]
+	}
+
+	if !f.cfg.Compress {
+		return
+	}
+
+	for _, name := range files {
+		if strings.HasSuffix(name, ".gz") {
+			continue
+		}
+		if err := gzipFile(name); err != nil {
+			log.Println("ERROR: logger: compressing", name, err)
+		}
+	}
+}
+
+// gzipFile compresses the file to a .gz next to it and removes the
+// original. The compressed file only shows up once it's complete.
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + ".gz.tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, name+".gz"); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(name)
+}
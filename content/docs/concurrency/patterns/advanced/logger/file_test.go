@@ -0,0 +1,280 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate the File sink rotates, prunes, compresses and reopens
+// its file, and recovers when the file can't be opened.
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// deviceFile is an open file whose writes go to a Device.
+type deviceFile struct {
+	*os.File
+	d *Device
+}
+
+// Write implements the io.Writer interface.
+func (df deviceFile) Write(p []byte) (int, error) {
+	return df.d.Write(p)
+}
+
+// newFile returns a File sink writing to app.log in a temporary directory.
+func newFile(t *testing.T, cfg FileConfig) *File {
+	t.Helper()
+
+	cfg.Path = filepath.Join(t.TempDir(), "app.log")
+	f, err := NewFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	return f
+}
+
+// write writes the lines to the sink, failing the test on an error.
+func write(t *testing.T, f *File, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		if _, err := f.Write([]byte(line + "\n")); err != nil {
+			t.Fatalf("\t%s\tShould write %q : %v", failed, line, err)
+		}
+	}
+}
+
+// contents returns what the file has in it, uncompressing .gz files.
+func contents(t *testing.T, name string) string {
+	t.Helper()
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestFileRotate validates the file is rotated by size and by age.
+func TestFileRotate(t *testing.T) {
+	t.Log("Given the need to rotate the log file.")
+	{
+		t.Log("\tWhen writing 3 lines of 9 bytes with a maximum size of 20.")
+		{
+			f := newFile(t, FileConfig{MaxSize: 20})
+			write(t, f, "line 0001", "line 0002", "line 0003")
+
+			rotated := f.rotated()
+			if len(rotated) != 1 || contents(t, rotated[0]) != "line 0001\nline 0002\n" {
+				t.Fatalf("\t%s\tShould rotate the first 2 lines : %q", failed, rotated)
+			}
+			t.Logf("\t%s\tShould rotate the first 2 lines.", succeed)
+
+			if got := contents(t, f.cfg.Path); got != "line 0003\n" {
+				t.Fatalf("\t%s\tShould write the 3rd line to a new file : %q", failed, got)
+			}
+			t.Logf("\t%s\tShould write the 3rd line to a new file.", succeed)
+		}
+
+		t.Log("\tWhen writing to a file older than its maximum age.")
+		{
+			f := newFile(t, FileConfig{MaxAge: time.Hour})
+			write(t, f, "old")
+
+			f.mu.Lock()
+			f.opened = f.opened.Add(-time.Hour)
+			f.mu.Unlock()
+
+			write(t, f, "new")
+
+			rotated := f.rotated()
+			if len(rotated) != 1 || contents(t, rotated[0]) != "old\n" || contents(t, f.cfg.Path) != "new\n" {
+				t.Fatalf("\t%s\tShould rotate the old file before writing : %q", failed, rotated)
+			}
+			t.Logf("\t%s\tShould rotate the old file before writing.", succeed)
+		}
+	}
+}
+
+// TestFileCleanup validates rotated files are pruned and compressed.
+func TestFileCleanup(t *testing.T) {
+	t.Log("Given the need to clean up rotated files.")
+	{
+		t.Log("\tWhen rotating 5 times keeping 2 files.")
+		{
+			f := newFile(t, FileConfig{Keep: 2})
+			for _, line := range []string{"1", "2", "3", "4", "5"} {
+				write(t, f, line)
+				if err := f.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			waitFor(t, "2 rotated files", func() bool { return len(f.rotated()) == 2 })
+
+			rotated := f.rotated()
+			if contents(t, rotated[0]) != "4\n" || contents(t, rotated[1]) != "5\n" {
+				t.Fatalf("\t%s\tShould keep the newest files : %q", failed, rotated)
+			}
+			t.Logf("\t%s\tShould keep the newest files.", succeed)
+		}
+
+		t.Log("\tWhen rotating with compression.")
+		{
+			f := newFile(t, FileConfig{Compress: true})
+			write(t, f, "compress me", "and me")
+			if err := f.Rotate(); err != nil {
+				t.Fatal(err)
+			}
+
+			waitFor(t, "the file compressed", func() bool {
+				r := f.rotated()
+				return len(r) == 1 && strings.HasSuffix(r[0], ".gz")
+			})
+
+			if got := contents(t, f.rotated()[0]); got != "compress me\nand me\n" {
+				t.Fatalf("\t%s\tShould gzip the rotated file : %q", failed, got)
+			}
+			t.Logf("\t%s\tShould gzip the rotated file.", succeed)
+		}
+	}
+}
+
+// TestFileReopen validates the file is reopened after being moved, and
+// that the sink recovers when the file can't be opened.
+func TestFileReopen(t *testing.T) {
+	t.Log("Given the need to reopen the log file.")
+	{
+		t.Log("\tWhen the file is moved out of the way like logrotate does.")
+		{
+			f := newFile(t, FileConfig{})
+			write(t, f, "a")
+
+			moved := f.cfg.Path + ".1"
+			if err := os.Rename(f.cfg.Path, moved); err != nil {
+				t.Fatal(err)
+			}
+			write(t, f, "b")
+
+			if err := f.Reopen(); err != nil {
+				t.Fatalf("\t%s\tShould reopen : %v", failed, err)
+			}
+			write(t, f, "c")
+
+			if contents(t, moved) != "a\nb\n" || contents(t, f.cfg.Path) != "c\n" {
+				t.Fatalf("\t%s\tShould write to a new file once reopened : %q, %q", failed, contents(t, moved), contents(t, f.cfg.Path))
+			}
+			t.Logf("\t%s\tShould write to a new file once reopened.", succeed)
+		}
+
+		t.Log("\tWhen the file can't be opened after a rotation.")
+		{
+			f := newFile(t, FileConfig{})
+			f.openFile = func(string) (handle, error) {
+				return nil, syscall.EMFILE
+			}
+
+			if err := f.Rotate(); !errors.Is(err, syscall.EMFILE) {
+				t.Fatalf("\t%s\tShould fail to rotate : %v", failed, err)
+			}
+			if _, err := f.Write([]byte("lost\n")); !errors.Is(err, syscall.EMFILE) {
+				t.Fatalf("\t%s\tShould fail to write while it can't open : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould fail while the file can't be opened.", succeed)
+
+			f.openFile = openFile
+			write(t, f, "back")
+
+			if got := contents(t, f.cfg.Path); got != "back\n" {
+				t.Fatalf("\t%s\tShould write again once the file opens : %q", failed, got)
+			}
+			t.Logf("\t%s\tShould write again once the file opens.", succeed)
+
+			f.openFile = func(string) (handle, error) {
+				return nil, syscall.ENOSPC
+			}
+			f.Rotate()
+			f.openFile = openFile
+
+			if err := f.Reopen(); err != nil {
+				t.Fatalf("\t%s\tShould reopen after a failed open : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould reopen after a failed open.", succeed)
+		}
+
+		t.Log("\tWhen the sink is closed.")
+		{
+			f := newFile(t, FileConfig{})
+			f.Close()
+
+			_, err := f.Write([]byte("late\n"))
+			if err != os.ErrClosed || f.Reopen() != os.ErrClosed {
+				t.Fatalf("\t%s\tShould refuse to write or reopen : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould refuse to write or reopen.", succeed)
+		}
+	}
+}
+
+// TestFilePartial validates a write the disk only partly accepts is ended
+// with a newline.
+func TestFilePartial(t *testing.T) {
+	t.Log("Given the need to keep lines whole when a write is cut short.")
+	{
+		t.Log("\tWhen the device only takes 5 bytes of a line.")
+		{
+			d := NewDevice(nil)
+			f := newFile(t, FileConfig{})
+			f.openFile = func(path string) (handle, error) {
+				file, err := openFile(path)
+				if err != nil {
+					return nil, err
+				}
+				return deviceFile{File: file.(*os.File), d: d}, nil
+			}
+			if err := f.Reopen(); err != nil {
+				t.Fatal(err)
+			}
+
+			d.Partial(1, 5)
+			if n, err := f.Write([]byte("hello world\n")); n != 5 || err != io.ErrShortWrite {
+				t.Fatalf("\t%s\tShould report the short write : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould report the short write.", succeed)
+
+			write(t, f, "next")
+
+			got := d.Lines()
+			if strings.Join(got, ",") != "hello,next" {
+				t.Fatalf("\t%s\tShould start the next line on its own : %q", failed, got)
+			}
+			t.Logf("\t%s\tShould start the next line on its own.", succeed)
+		}
+	}
+}
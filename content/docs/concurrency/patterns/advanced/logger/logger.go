@@ -6,62 +6,335 @@
 package logger
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Level represents the severity of a log message.
+type Level int
+
+// Set of levels a message can be logged at.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// Option configures a logger during construction.
+type Option func(l *Logger)
+
+// WithLevel sets the minimum level a message needs to be logged. Messages
+// below this level are discarded before they are encoded.
+func WithLevel(lvl Level) Option {
+	return func(l *Logger) {
+		l.level = lvl
+	}
+}
+
+// WithEncoder sets the encoder used to format leveled messages.
+func WithEncoder(enc Encoder) Option {
+	return func(l *Logger) {
+		l.enc = enc
+	}
+}
+
+// WithName sets the name the logger is reported under in its stats,
+// which tells the sinks of a Fanout apart.
+func WithName(name string) Option {
+	return func(l *Logger) {
+		l.name = name
+	}
+}
+
+// WithStallThreshold sets how long a single write can block before the
+// logger reports itself as unhealthy.
+func WithStallThreshold(d time.Duration) Option {
+	return func(l *Logger) {
+		l.stall = d
+	}
+}
+
+// WithBatch sets the maximum number of lines written to the device with a
+// single Write and how long a line can wait for the batch to fill. With an
+// interval of zero, whatever is queued is written as soon as the buffer
+// is empty.
+func WithBatch(size int, interval time.Duration) Option {
+	return func(l *Logger) {
+		if size < 1 {
+			size = 1
+		}
+		l.batchSize = size
+		l.flushEvery = interval
+	}
+}
+
+// Stats represents the number of lines that made it through the logger.
+// Evicted, TimedOut, Sampled and Failed break down the lines counted in
+// Dropped by what dropped them.
+type Stats struct {
+	Name     string // Name of the logger.
+	Policy   string // Name of the overflow policy.
+	Accepted uint64 // Lines placed in the buffer.
+	Dropped  uint64 // Lines that will never reach the device.
+	Evicted  uint64 // Oldest lines removed from the buffer to make room.
+	TimedOut uint64 // Lines dropped after waiting too long for room.
+	Sampled  uint64 // Lines dropped by sampling while the buffer was full.
+	Failed   uint64 // Lines lost because the device returned an error.
+	Blocked  uint64 // Calls that had to wait for room in the buffer.
+	Written  uint64 // Lines written to the device.
+}
+
 // Logger ...
 type Logger struct {
-	ch chan string
-	wg sync.WaitGroup
+	name       string
+	q          queue
+	ring       bool
+	wrapQueue  func(q queue) queue
+	flush      chan chan struct{}
+	shutdown   chan struct{}
+	abandon    chan struct{}
+	done       chan struct{}
+	level      Level
+	enc        Encoder
+	overflow   Overflow
+	batchSize  int
+	flushEvery time.Duration
+	stall      time.Duration
+	clock      Clock
+
+	closed      atomic.Bool
+	active      atomic.Int64 // Callers inside Println, the writer waits for them on shutdown.
+	closeOnce   sync.Once
+	abandonOnce sync.Once
+
+	accepted  atomic.Uint64
+	dropped   atomic.Uint64
+	evicted   atomic.Uint64
+	timedOut  atomic.Uint64
+	sampled   atomic.Uint64
+	failed    atomic.Uint64
+	blocked   atomic.Uint64
+	samples   atomic.Uint64
+	written   atomic.Uint64
+	dropSince atomic.Int64  // Unix nano of the first drop not yet reported.
+	writing   atomic.Int64  // Unix nano the current write started, 0 when idle.
+	pending   atomic.Uint64 // Lines in the batch the writer is holding.
 }
 
 // New is a factory function that will return a logger.
 // We should be using pointer semantics when you can't make copies of loggers
-// anymore because we can't make a copy of the WaitGroup, that would create a
-// different WaitGroup. Plus, there's only one logger. We only want one logger.
+// anymore because we can't make a copy of the counters and the sync.Once
+// values, that would create different ones. Plus, there's only one logger.
+// We only want one logger.
 // w is what device we want to write to.
 // cap is capacity of our buffer and only the caller can tell us.
-func New(w io.Writer, cap int) *Logger {
+// opts are optional settings like the minimum level, the encoder and what
+// to do when the buffer is full.
+func New(w io.Writer, cap int, opts ...Option) *Logger {
 	// Using value semantic on construction because we are assigning it to
 	// variable
 	l := Logger{
-		ch: make(chan string, cap),
+		flush:     make(chan chan struct{}),
+		shutdown:  make(chan struct{}),
+		abandon:   make(chan struct{}),
+		done:      make(chan struct{}),
+		level:     LevelInfo,
+		enc:       TextEncoder{},
+		batchSize: 64,
+		stall:     5 * time.Second,
+		clock:     realClock{},
 	}
 
-	l.wg.Add(1)
+	for _, opt := range opts {
+		opt(&l)
+	}
+
+	// The buffer can only be made once we know which one was asked for.
+	switch {
+	case l.ring:
+		l.q = newRingQueue(cap)
+	default:
+		l.q = make(chanQueue, cap)
+	}
+	if l.wrapQueue != nil {
+		l.q = l.wrapQueue(l.q)
+	}
 
 	// Goroutine job is to perform these writes.
-	go func() {
-		defer l.wg.Done()
-		for v := range l.ch {
-			// Write whatever data we receive off the channel to the device
-			// that we passed that will do it through the fmt.Fprint function.
-			fmt.Fprintln(w, v)
-		}
-	}()
+	go l.writer(w)
 
 	// Escape analysis: potential allocation
 	return &l
 }
 
+// ErrClosed is returned when the logger has already been closed.
+var ErrClosed = errors.New("logger closed")
+
 // Close give our API the ability to clean shutdown the Goroutine in order
-// of fashion in our factory function.
-func (l *Logger) Close() {
-	// Terminate the `for` loop in the Goroutine which will the call
-	// WaitGroup.Done()
-	close(l.ch)
-	l.wg.Wait()
+// of fashion in our factory function. The Goroutine writes out everything
+// still in the buffer before it terminates. If the device is stuck and the
+// context is done first, Close gives up on the device and returns the
+// number of lines that were abandoned with the context's error.
+//
+// Calling Println after Close is safe, the lines are counted as drops.
+func (l *Logger) Close(ctx context.Context) (int, error) {
+	// Signal the Goroutine to drain and terminate. We don't close the
+	// buffer because callers may still be sending on it.
+	l.closeOnce.Do(func() {
+		l.closed.Store(true)
+		close(l.shutdown)
+	})
+
+	select {
+	case <-l.done:
+		return 0, nil
+
+	case <-ctx.Done():
+		l.abandonOnce.Do(func() {
+			close(l.abandon)
+		})
+		return l.q.len() + int(l.pending.Load()), ctx.Err()
+	}
+}
+
+// Healthy reports whether the device is keeping up. It returns false once
+// a single write has been blocked longer than the stall threshold.
+func (l *Logger) Healthy() bool {
+	start := l.writing.Load()
+	if start == 0 {
+		return true
+	}
+
+	return l.clock.Now().Sub(time.Unix(0, start)) < l.stall
+}
+
+// Flush blocks until every message queued before the call has been
+// written to the device, or the context is done.
+func (l *Logger) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+
+	select {
+	case l.flush <- done:
+	case <-l.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Println prints to the logger
 func (l *Logger) Println(v string) {
-	select {
-	case l.ch <- v:
-	default:
-		// If line 56 is going to block there's no room in the ch buffer, then
-		// we want to drop, dropping these logs.
-		fmt.Println("DROP") // Print so we can see we no longer blocking
+
+	// Say we are here before checking for Close, so the writer either
+	// waits for our line or we see the logger is closed and drop it.
+	l.active.Add(1)
+	defer l.active.Add(-1)
+
+	if l.closed.Load() {
+		l.drop()
+		return
 	}
+
+	if l.q.push(v) {
+		l.accepted.Add(1)
+		return
+	}
+
+	// If the send is going to block there's no room in the buffer, then
+	// the overflow policy decides what happens. By default we want to
+	// drop, dropping these logs.
+	l.overflowed(v)
+}
+
+// Stats returns the number of lines accepted, dropped and written so far.
+func (l *Logger) Stats() Stats {
+	return Stats{
+		Name:     l.name,
+		Policy:   l.overflow.String(),
+		Accepted: l.accepted.Load(),
+		Dropped:  l.dropped.Load(),
+		Evicted:  l.evicted.Load(),
+		TimedOut: l.timedOut.Load(),
+		Sampled:  l.sampled.Load(),
+		Failed:   l.failed.Load(),
+		Blocked:  l.blocked.Load(),
+		Written:  l.written.Load(),
+	}
+}
+
+// Debug logs a message with key/value fields at the debug level.
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.log(LevelDebug, msg, kv)
+}
+
+// Info logs a message with key/value fields at the info level.
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.log(LevelInfo, msg, kv)
+}
+
+// Warn logs a message with key/value fields at the warn level.
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.log(LevelWarn, msg, kv)
+}
+
+// Error logs a message with key/value fields at the error level.
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.log(LevelError, msg, kv)
+}
+
+// Enabled reports whether messages at the level will be logged.
+func (l *Logger) Enabled(lvl Level) bool {
+	return lvl >= l.level
+}
+
+// log encodes the message on the caller's goroutine and hands the line
+// to Println, so leveled messages get the same drop behavior.
+func (l *Logger) log(lvl Level, msg string, kv []interface{}) {
+	if !l.Enabled(lvl) {
+		return
+	}
+
+	l.Log(Entry{
+		Time:    l.clock.Now(),
+		Level:   lvl,
+		Message: msg,
+		Fields:  kv,
+	})
+}
+
+// Log encodes the entry if its level is enabled and logs the line.
+func (l *Logger) Log(e Entry) {
+	if !l.Enabled(e.Level) {
+		return
+	}
+
+	l.Println(l.enc.Encode(e))
 }
@@ -0,0 +1,519 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate the logger's drop counts and ordering guarantees under
+// each overflow policy, using a scripted device and a fake clock.
+package logger
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const succeed = "✓"
+const failed = "✗"
+
+// start is the time every fake clock starts at.
+var start = time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+// waitFor yields until the condition is true. The deadline only exists so
+// a broken test fails instead of hanging, nothing here sleeps.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("\t%s\tShould see %s.", failed, what)
+		}
+		runtime.Gosched()
+	}
+}
+
+// backends are the buffers the logger can be built with. The policies
+// must behave the same with either.
+var backends = []struct {
+	name string
+	opts []Option
+}{
+	{"channel", nil},
+	{"ring buffer", []Option{WithRingBuffer()}},
+}
+
+// stalled returns a logger with a buffer of 4 lines whose device is stuck
+// writing the line "0", which leaves the buffer empty.
+func stalled(t *testing.T, o Overflow, opts ...Option) (*Logger, *Device, *FakeClock) {
+	t.Helper()
+
+	clock := NewFakeClock(start)
+	d := NewDevice(clock)
+	d.Stall()
+
+	opts = append([]Option{WithClock(clock), WithOverflow(o)}, opts...)
+	l := New(d, 4, opts...)
+	l.Println("0")
+	<-d.Parked()
+
+	return l, d, clock
+}
+
+// lines returns the lines from first to last as strings.
+func lines(first, last int) []string {
+	var s []string
+	for i := first; i <= last; i++ {
+		s = append(s, strconv.Itoa(i))
+	}
+	return s
+}
+
+// checkLines validates the device received the lines in order, with the
+// report of the drops right after the first line.
+func checkLines(t *testing.T, d *Device, dropped int, want []string) {
+	t.Helper()
+
+	got := d.Lines()
+	if dropped > 0 {
+		if len(got) < 2 || !strings.Contains(got[1], strconv.Itoa(dropped)+" messages dropped") {
+			t.Fatalf("\t%s\tShould report %d drops after the first line : %q", failed, dropped, got)
+		}
+		t.Logf("\t%s\tShould report %d drops after the first line.", succeed, dropped)
+		got = append(got[:1:1], got[2:]...)
+	}
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("\t%s\tShould write %q : %q", failed, want, got)
+	}
+	t.Logf("\t%s\tShould write %q in order.", succeed, want)
+}
+
+// checkStats validates the stats match what we expect.
+func checkStats(t *testing.T, got Stats, want Stats) {
+	t.Helper()
+
+	want.Name = got.Name
+	want.Policy = got.Policy
+	if got != want {
+		t.Fatalf("\t%s\tShould have stats %+v : %+v", failed, want, got)
+	}
+	t.Logf("\t%s\tShould have stats %+v.", succeed, want)
+}
+
+// TestDropNewest validates new lines are dropped while the device is stuck.
+func TestDropNewest(t *testing.T) {
+	t.Log("Given the need to drop the newest lines when the buffer is full.")
+	{
+		for _, bk := range backends {
+			t.Logf("\tWhen logging 10 lines into a %s of 4 with a stuck device.", bk.name)
+			{
+				l, d, _ := stalled(t, DropNewest(), bk.opts...)
+				for _, v := range lines(1, 10) {
+					l.Println(v)
+				}
+				d.Resume()
+				l.Close(context.Background())
+
+				checkLines(t, d, 6, lines(0, 4))
+				checkStats(t, l.Stats(), Stats{Accepted: 5, Dropped: 6, Written: 5})
+			}
+		}
+	}
+}
+
+// TestDropOldest validates the oldest lines make room for new ones.
+func TestDropOldest(t *testing.T) {
+	t.Log("Given the need to drop the oldest lines when the buffer is full.")
+	{
+		for _, bk := range backends {
+			t.Logf("\tWhen logging 10 lines into a %s of 4 with a stuck device.", bk.name)
+			{
+				l, d, _ := stalled(t, DropOldest(), bk.opts...)
+				for _, v := range lines(1, 10) {
+					l.Println(v)
+				}
+				d.Resume()
+				l.Close(context.Background())
+
+				checkLines(t, d, 6, append([]string{"0"}, lines(7, 10)...))
+				checkStats(t, l.Stats(), Stats{Accepted: 11, Dropped: 6, Evicted: 6, Written: 5})
+			}
+		}
+	}
+}
+
+// TestBlock validates callers wait for room and nothing is lost.
+func TestBlock(t *testing.T) {
+	t.Log("Given the need to block callers when the buffer is full.")
+	{
+		for _, bk := range backends {
+			t.Logf("\tWhen logging 10 lines into a %s of 4 with a stuck device.", bk.name)
+			{
+				l, d, _ := stalled(t, Block(), bk.opts...)
+
+				done := make(chan struct{})
+				go func() {
+					for _, v := range lines(1, 10) {
+						l.Println(v)
+					}
+					close(done)
+				}()
+
+				waitFor(t, "the caller blocked", func() bool { return l.Stats().Blocked > 0 })
+				t.Logf("\t%s\tShould block the caller.", succeed)
+
+				d.Resume()
+				<-done
+				l.Close(context.Background())
+
+				checkLines(t, d, 0, lines(0, 10))
+				if s := l.Stats(); s.Dropped != 0 || s.Written != 11 {
+					t.Fatalf("\t%s\tShould write every line : %+v", failed, s)
+				}
+				t.Logf("\t%s\tShould write every line.", succeed)
+			}
+		}
+	}
+}
+
+// TestBlockTimeout validates callers give up after the timeout.
+func TestBlockTimeout(t *testing.T) {
+	t.Log("Given the need to block callers for a limited time when the buffer is full.")
+	{
+		for _, bk := range backends {
+			t.Logf("\tWhen logging a 5th line into a %s of 4 with a stuck device.", bk.name)
+			{
+				l, d, clock := stalled(t, BlockTimeout(time.Second), bk.opts...)
+				for _, v := range lines(1, 4) {
+					l.Println(v)
+				}
+
+				done := make(chan struct{})
+				go func() {
+					l.Println("5")
+					close(done)
+				}()
+
+				waitFor(t, "the caller waiting on the clock", func() bool { return clock.Timers() == 1 })
+				clock.Advance(time.Second)
+				<-done
+				t.Logf("\t%s\tShould give up once the timeout passes.", succeed)
+
+				d.Resume()
+				l.Close(context.Background())
+
+				checkLines(t, d, 1, lines(0, 4))
+				checkStats(t, l.Stats(), Stats{Accepted: 5, Dropped: 1, TimedOut: 1, Blocked: 1, Written: 5})
+			}
+		}
+	}
+}
+
+// TestSample validates 1 in N lines are kept while the buffer is full,
+// making room by dropping the oldest, and callers never wait.
+func TestSample(t *testing.T) {
+	t.Log("Given the need to sample lines when the buffer is full.")
+	{
+		for _, bk := range backends {
+			t.Logf("\tWhen logging 10 lines into a %s of 4 with a stuck device.", bk.name)
+			{
+				l, d, _ := stalled(t, Sample(3), bk.opts...)
+				for _, v := range lines(1, 10) {
+					l.Println(v)
+				}
+				t.Logf("\t%s\tShould not block the caller.", succeed)
+
+				d.Resume()
+				l.Close(context.Background())
+
+				// Lines 7 and 10 are kept in place of 1 and 2, the other
+				// 4 lines that didn't fit are sampled out.
+				checkLines(t, d, 6, []string{"0", "3", "4", "7", "10"})
+				checkStats(t, l.Stats(), Stats{Accepted: 7, Dropped: 6, Sampled: 4, Evicted: 2, Written: 5})
+			}
+		}
+	}
+}
+
+// TestDeviceFaults validates lines lost to device errors are counted.
+func TestDeviceFaults(t *testing.T) {
+	errDiskFull := errors.New("no space left on device")
+
+	t.Log("Given the need to survive a device returning errors.")
+	{
+		t.Log("\tWhen the device fails a write and then recovers.")
+		{
+			d := NewDevice(nil)
+			d.Fail(1, errDiskFull)
+
+			l := New(d, 4)
+			l.Println("a")
+			l.Flush(context.Background())
+			l.Println("b")
+			l.Close(context.Background())
+
+			got := d.Lines()
+			if len(got) != 2 || got[0] != "b" || !strings.Contains(got[1], "1 messages dropped") {
+				t.Fatalf("\t%s\tShould write the next line and report the loss : %q", failed, got)
+			}
+			t.Logf("\t%s\tShould write the next line and report the loss.", succeed)
+			checkStats(t, l.Stats(), Stats{Accepted: 2, Dropped: 1, Failed: 1, Written: 1})
+		}
+
+		t.Log("\tWhen the device only accepts part of a write.")
+		{
+			d := NewDevice(nil)
+			d.Partial(1, 2)
+
+			l := New(d, 4)
+			l.Println("hello")
+			if err := l.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			l.Println("world")
+			l.Close(context.Background())
+
+			checkStats(t, l.Stats(), Stats{Accepted: 2, Dropped: 1, Failed: 1, Written: 1})
+
+			// The torn line stays, followed by the report of its loss.
+			got := d.Lines()
+			if len(got) != 3 || got[0] != "he" || got[1] != "world" || !strings.Contains(got[2], "1 messages dropped") {
+				t.Fatalf("\t%s\tShould start the next line on its own : %q", failed, got)
+			}
+			t.Logf("\t%s\tShould start the next line on its own.", succeed)
+		}
+	}
+}
+
+// TestBatch validates lines wait for the flush interval or a Flush call.
+func TestBatch(t *testing.T) {
+	t.Log("Given the need to write lines to the device in batches.")
+	{
+		t.Log("\tWhen logging fewer lines than the batch size.")
+		{
+			clock := NewFakeClock(start)
+			d := NewDevice(clock)
+			l := New(d, 10, WithClock(clock), WithBatch(5, time.Second))
+
+			for _, v := range lines(1, 3) {
+				l.Println(v)
+			}
+
+			waitFor(t, "the batch waiting on the clock", func() bool { return clock.Timers() == 1 })
+			if d.Writes() != 0 {
+				t.Fatalf("\t%s\tShould hold the lines until the interval passes : %d writes", failed, d.Writes())
+			}
+			t.Logf("\t%s\tShould hold the lines until the interval passes.", succeed)
+
+			clock.Advance(time.Second)
+			waitFor(t, "the batch written", func() bool { return d.Writes() == 1 })
+			checkLines(t, d, 0, lines(1, 3))
+
+			l.Println("4")
+			if err := l.Flush(context.Background()); err != nil || d.Writes() != 2 {
+				t.Fatalf("\t%s\tShould write the line on Flush : %v, %d writes", failed, err, d.Writes())
+			}
+			t.Logf("\t%s\tShould write the line on Flush.", succeed)
+
+			l.Close(context.Background())
+		}
+	}
+}
+
+// TestHealthy validates the logger reports a stuck device.
+func TestHealthy(t *testing.T) {
+	t.Log("Given the need to know when the device is stuck.")
+	{
+		t.Log("\tWhen a write takes longer than the stall threshold.")
+		{
+			clock := NewFakeClock(start)
+			d := NewDevice(clock)
+			d.SlowFor(1, 3*time.Second)
+
+			l := New(d, 4, WithClock(clock), WithStallThreshold(2*time.Second))
+			l.Println("0")
+			<-d.Parked()
+
+			clock.Advance(time.Second)
+			if !l.Healthy() {
+				t.Fatalf("\t%s\tShould be healthy before the threshold.", failed)
+			}
+			t.Logf("\t%s\tShould be healthy before the threshold.", succeed)
+
+			clock.Advance(time.Second)
+			if l.Healthy() {
+				t.Fatalf("\t%s\tShould be unhealthy at the threshold.", failed)
+			}
+			t.Logf("\t%s\tShould be unhealthy at the threshold.", succeed)
+
+			clock.Advance(time.Second)
+			waitFor(t, "the slow write finish", func() bool { return d.Writes() == 1 })
+			waitFor(t, "the logger healthy again", l.Healthy)
+			t.Logf("\t%s\tShould be healthy once the write finishes.", succeed)
+
+			l.Close(context.Background())
+		}
+	}
+}
+
+// TestClose validates Close gives up on a stuck device.
+func TestClose(t *testing.T) {
+	t.Log("Given the need to shut down with a stuck device.")
+	{
+		t.Log("\tWhen the context is done before the buffer drains.")
+		{
+			clock := NewFakeClock(start)
+			d := NewDevice(clock)
+			d.StallFor(time.Minute)
+
+			l := New(d, 4, WithClock(clock))
+			l.Println("0")
+			<-d.Parked()
+			for _, v := range lines(1, 3) {
+				l.Println(v)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			n, err := l.Close(ctx)
+			if n != 4 || err != context.Canceled {
+				t.Fatalf("\t%s\tShould abandon 4 lines : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould abandon 4 lines.", succeed)
+
+			l.Println("after")
+			if s := l.Stats(); s.Dropped != 1 {
+				t.Fatalf("\t%s\tShould drop lines logged after Close : %+v", failed, s)
+			}
+			t.Logf("\t%s\tShould drop lines logged after Close.", succeed)
+
+			clock.Advance(time.Minute)
+			waitFor(t, "the stuck write finish", func() bool { return d.Writes() >= 1 })
+			if err := l.Flush(context.Background()); err != ErrClosed {
+				t.Fatalf("\t%s\tShould not flush a closed logger : %v", failed, err)
+			}
+
+			for _, v := range d.Lines() {
+				if v == "1" {
+					t.Fatalf("\t%s\tShould not write abandoned lines : %q", failed, d.Lines())
+				}
+			}
+			t.Logf("\t%s\tShould not write abandoned lines.", succeed)
+		}
+	}
+}
+
+// gatedQueue is a queue whose pushes wait for the gate to open, so a test
+// can hold a caller between checking for Close and pushing its line.
+type gatedQueue struct {
+	queue
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+// push implements the queue interface.
+func (g gatedQueue) push(v string) bool {
+	g.entered <- struct{}{}
+	<-g.gate
+	return g.queue.push(v)
+}
+
+// TestCloseRace validates a line logged by a caller that got past the
+// closed check before Close is written, not lost.
+func TestCloseRace(t *testing.T) {
+	t.Log("Given the need to close the logger while a caller is logging.")
+	{
+		for _, bk := range backends {
+			t.Logf("\tWhen a caller pushes into a %s as the writer shuts down.", bk.name)
+			{
+				g := gatedQueue{entered: make(chan struct{}), gate: make(chan struct{})}
+				wrap := withQueue(func(q queue) queue {
+					g.queue = q
+					return g
+				})
+
+				d := NewDevice(nil)
+				l := New(d, 4, append([]Option{wrap}, bk.opts...)...)
+
+				logged := make(chan struct{})
+				go func() {
+					l.Println("late")
+					close(logged)
+				}()
+				<-g.entered
+
+				closed := make(chan int)
+				go func() {
+					n, _ := l.Close(context.Background())
+					closed <- n
+				}()
+
+				// Give the writer every chance to finish shutting down
+				// before the caller pushes its line.
+				waitFor(t, "the logger closing", func() bool { return l.closed.Load() })
+				for i := 0; i < 1000; i++ {
+					runtime.Gosched()
+				}
+
+				close(g.gate)
+				<-logged
+				n := <-closed
+
+				s := l.Stats()
+				if n != 0 || s.Accepted != 1 || s.Written != 1 || strings.Join(d.Lines(), ",") != "late" {
+					t.Fatalf("\t%s\tShould write the line : %d, %+v, %q", failed, n, s, d.Lines())
+				}
+				t.Logf("\t%s\tShould write the line.", succeed)
+			}
+		}
+	}
+}
+
+// BenchmarkPrintln measures the cost of logging under each policy, with
+// a device that keeps up and with one that is stuck.
+func BenchmarkPrintln(b *testing.B) {
+	policies := []Overflow{DropNewest(), DropOldest(), Block(), BlockTimeout(time.Millisecond), Sample(10)}
+
+	for _, o := range policies {
+		b.Run(o.String(), func(b *testing.B) {
+			l := New(NewDevice(nil), 1000, WithOverflow(o))
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					l.Println("log data")
+				}
+			})
+
+			b.StopTimer()
+			l.Close(context.Background())
+		})
+	}
+
+	// Only the policies that never block make sense against a device
+	// that never returns.
+	for _, o := range []Overflow{DropNewest(), DropOldest()} {
+		b.Run(o.String()+"/stalled", func(b *testing.B) {
+			d := NewDevice(nil)
+			d.Stall()
+			l := New(d, 1000, WithOverflow(o))
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					l.Println("log data")
+				}
+			})
+
+			b.StopTimer()
+			d.Resume()
+			l.Close(context.Background())
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// Set of policies for handling a full buffer.
+const (
+	policyDropNewest = iota
+	policyDropOldest
+	policyBlock
+	policyBlockTimeout
+	policySample
+)
+
+// Overflow represents what the logger does with a message when the buffer
+// is full because the device can't keep up.
+type Overflow struct {
+	policy  int
+	timeout time.Duration
+	every   uint64
+}
+
+// DropNewest drops the message being logged. This is the default and never
+// blocks the caller.
+func DropNewest() Overflow {
+	return Overflow{policy: policyDropNewest}
+}
+
+// DropOldest removes the oldest message in the buffer to make room for the
+// message being logged. It never blocks the caller.
+func DropOldest() Overflow {
+	return Overflow{policy: policyDropOldest}
+}
+
+// Block makes the caller wait until there is room in the buffer.
+func Block() Overflow {
+	return Overflow{policy: policyBlock}
+}
+
+// BlockTimeout makes the caller wait for room in the buffer for up to the
+// specified duration, then drops the message.
+func BlockTimeout(d time.Duration) Overflow {
+	return Overflow{policy: policyBlockTimeout, timeout: d}
+}
+
+// Sample keeps 1 in every n messages while the buffer is full by removing
+// the oldest message in the buffer to make room, and drops the rest. It
+// never blocks the caller.
+func Sample(n int) Overflow {
+	if n < 1 {
+		n = 1
+	}
+	return Overflow{policy: policySample, every: uint64(n)}
+}
+
+// String returns the name of the policy.
+func (o Overflow) String() string {
+	switch o.policy {
+	case policyDropNewest:
+		return "drop-newest"
+	case policyDropOldest:
+		return "drop-oldest"
+	case policyBlock:
+		return "block"
+	case policyBlockTimeout:
+		return fmt.Sprintf("block-timeout(%v)", o.timeout)
+	case policySample:
+		return fmt.Sprintf("sample(1/%d)", o.every)
+	}
+	return "unknown"
+}
+
+// WithOverflow sets the policy used when the buffer is full.
+func WithOverflow(o Overflow) Option {
+	return func(l *Logger) {
+		l.overflow = o
+	}
+}
+
+// overflowed applies the logger's policy to a message that didn't fit in
+// the buffer.
+func (l *Logger) overflowed(v string) {
+	switch l.overflow.policy {
+	case policyDropOldest:
+		l.evict(v)
+
+	case policyBlock:
+		l.blocked.Add(1)
+		l.send(v)
+
+	case policyBlockTimeout:
+		l.blocked.Add(1)
+		t := l.clock.NewTimer(l.overflow.timeout)
+		defer t.Stop()
+
+		if l.q.pushWait(v, t.C(), l.shutdown) {
+			l.accepted.Add(1)
+			return
+		}
+
+		// A logger that is closed while we wait drops the message
+		// without it counting as a timeout.
+		select {
+		case <-l.shutdown:
+		default:
+			l.timedOut.Add(1)
+		}
+		l.drop()
+
+	case policySample:
+		if l.samples.Add(1)%l.overflow.every != 0 {
+			l.sampled.Add(1)
+			l.drop()
+			return
+		}
+
+		l.evict(v)
+
+	default:
+		l.drop()
+	}
+}
+
+// evict makes room for the message by removing the oldest message in the
+// buffer, without waiting.
+func (l *Logger) evict(v string) {
+
+	// Without a buffer there is nothing old to make room with.
+	if l.q.cap() == 0 {
+		l.drop()
+		return
+	}
+
+	for {
+		if l.q.push(v) {
+			l.accepted.Add(1)
+			return
+		}
+
+		// Make room by taking the oldest message out of the buffer.
+		// The writer may have beaten us to it, so try the send again.
+		if _, ok := l.q.pop(); ok {
+			l.evicted.Add(1)
+			l.drop()
+		}
+	}
+}
+
+// send waits for room in the buffer. A logger that is closed while we
+// wait will never make room, so the message is dropped.
+func (l *Logger) send(v string) {
+	if l.q.pushWait(v, nil, l.shutdown) {
+		l.accepted.Add(1)
+		return
+	}
+	l.drop()
+}
+
+// drop counts a message that didn't make it to the device and remembers
+// when the gap started, the writer reports it later.
+func (l *Logger) drop() {
+	l.dropped.Add(1)
+	l.dropSince.CompareAndSwap(0, l.clock.Now().UnixNano())
+}
@@ -0,0 +1,314 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package logger
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// queue declares the behavior of the buffer between the goroutines calling
+// Println and the writer goroutine. Only the writer waits on recv, callers
+// push and, to evict the oldest line, pop.
+type queue interface {
+
+	// push adds the line if there is room, without waiting.
+	push(v string) bool
+
+	// pushWait waits for room to add the line until the timeout fires or
+	// stop is closed. Either channel may be nil.
+	pushWait(v string, timeout <-chan time.Time, stop <-chan struct{}) bool
+
+	// pop removes the oldest line, without waiting.
+	pop() (string, bool)
+
+	// recv returns the channels the writer selects on for lines. Lines
+	// come on the first one, the second one only says lines are waiting
+	// to be popped. Either channel may be nil.
+	recv() (<-chan string, <-chan struct{})
+
+	len() int
+	cap() int
+}
+
+// WithRingBuffer makes the logger buffer lines in a lock-free ring instead
+// of a channel. It costs less for callers when many goroutines log at the
+// same time. The capacity is rounded up to a power of two.
+func WithRingBuffer() Option {
+	return func(l *Logger) {
+		l.ring = true
+	}
+}
+
+// withQueue wraps the buffer the logger makes, so tests can step in
+// between the callers and the buffer.
+func withQueue(wrap func(q queue) queue) Option {
+	return func(l *Logger) {
+		l.wrapQueue = wrap
+	}
+}
+
+// =============================================================================
+
+// chanQueue is the default queue, a buffered channel.
+type chanQueue chan string
+
+// push implements the queue interface.
+func (q chanQueue) push(v string) bool {
+	select {
+	case q <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+// pushWait implements the queue interface.
+func (q chanQueue) pushWait(v string, timeout <-chan time.Time, stop <-chan struct{}) bool {
+	select {
+	case q <- v:
+		return true
+	case <-timeout:
+		return false
+	case <-stop:
+		return false
+	}
+}
+
+// pop implements the queue interface.
+func (q chanQueue) pop() (string, bool) {
+	select {
+	case v := <-q:
+		return v, true
+	default:
+		return "", false
+	}
+}
+
+// recv implements the queue interface.
+func (q chanQueue) recv() (<-chan string, <-chan struct{}) {
+	return q, nil
+}
+
+// len implements the queue interface.
+func (q chanQueue) len() int {
+	return len(q)
+}
+
+// cap implements the queue interface.
+func (q chanQueue) cap() int {
+	return cap(q)
+}
+
+// =============================================================================
+
+// closedCh is a channel that is always ready to receive from.
+var closedCh = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// pad keeps the fields on either side of it on different cache lines so
+// the producers and the consumer don't fight over them.
+type pad [64]byte
+
+// cell is a slot in the ring. Its sequence tells whose turn it is: equal
+// to the position, the slot is free for a producer at that position, one
+// past it, the line is ready for the consumer.
+type cell struct {
+	seq atomic.Uint64
+	v   string
+}
+
+// ringQueue is a bounded queue based on Dmitry Vyukov's array queue.
+// Producers claim a position with a CAS on head and publish the line
+// through the cell's sequence, so no locks are taken. It's meant for many
+// producers and the single writer, but DropOldest makes producers pop as
+// well, which the algorithm allows.
+//
+// Channels are only used to put the writer or a blocked producer to sleep,
+// and only touched when someone is sleeping.
+type ringQueue struct {
+	_     pad
+	head  atomic.Uint64 // Next position to push at.
+	_     pad
+	tail  atomic.Uint64 // Next position to pop from.
+	_     pad
+	mask  uint64
+	cells []cell
+
+	sleeping atomic.Bool   // The writer may be waiting on ready.
+	waiting  atomic.Int64  // Producers waiting on space.
+	ready    chan struct{} // Wakes the writer after a push.
+	space    chan struct{} // Wakes a producer after a pop.
+}
+
+// newRingQueue returns a ring that holds at least the specified number
+// of lines, rounded up to a power of two.
+func newRingQueue(capacity int) *ringQueue {
+	size := 1
+	for size < capacity {
+		size <<= 1
+	}
+
+	q := ringQueue{
+		mask:  uint64(size - 1),
+		cells: make([]cell, size),
+		ready: make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+	}
+	for i := range q.cells {
+		q.cells[i].seq.Store(uint64(i))
+	}
+
+	return &q
+}
+
+// push implements the queue interface.
+func (q *ringQueue) push(v string) bool {
+	pos := q.head.Load()
+	for {
+		c := &q.cells[pos&q.mask]
+		seq := c.seq.Load()
+
+		switch dif := int64(seq) - int64(pos); {
+		case dif == 0:
+
+			// The slot is free, claim it before another producer does.
+			if !q.head.CompareAndSwap(pos, pos+1) {
+				pos = q.head.Load()
+				continue
+			}
+
+			c.v = v
+			c.seq.Store(pos + 1)
+
+			if q.sleeping.Load() && q.sleeping.CompareAndSwap(true, false) {
+				wake(q.ready)
+			}
+			return true
+
+		case dif < 0:
+
+			// The slot still holds a line from the last lap, we're full.
+			return false
+
+		default:
+
+			// Another producer took this position, catch up.
+			pos = q.head.Load()
+		}
+	}
+}
+
+// pushWait implements the queue interface.
+func (q *ringQueue) pushWait(v string, timeout <-chan time.Time, stop <-chan struct{}) bool {
+	if q.push(v) {
+		return true
+	}
+
+	// Say we are waiting before trying again, so a pop either sees us and
+	// signals or happened early enough for the push to succeed.
+	q.waiting.Add(1)
+	defer q.waiting.Add(-1)
+
+	for {
+		if q.push(v) {
+
+			// A pop only leaves one signal for many waiters, pass it
+			// on in case there is room for another.
+			if q.waiting.Load() > 1 {
+				wake(q.space)
+			}
+			return true
+		}
+
+		select {
+		case <-q.space:
+		case <-timeout:
+			return false
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// pop implements the queue interface.
+func (q *ringQueue) pop() (string, bool) {
+	pos := q.tail.Load()
+	for {
+		c := &q.cells[pos&q.mask]
+		seq := c.seq.Load()
+
+		switch dif := int64(seq) - int64(pos+1); {
+		case dif == 0:
+			if !q.tail.CompareAndSwap(pos, pos+1) {
+				pos = q.tail.Load()
+				continue
+			}
+
+			// Free the slot for the producer one lap ahead.
+			v := c.v
+			c.v = ""
+			c.seq.Store(pos + q.mask + 1)
+
+			if q.waiting.Load() > 0 {
+				wake(q.space)
+			}
+			return v, true
+
+		case dif < 0:
+
+			// Empty, or a producer claimed the slot and hasn't
+			// published the line yet.
+			return "", false
+
+		default:
+			pos = q.tail.Load()
+		}
+	}
+}
+
+// recv implements the queue interface. The writer calls it every time
+// around its loop, so a line already waiting makes it ready right away.
+func (q *ringQueue) recv() (<-chan string, <-chan struct{}) {
+	q.sleeping.Store(true)
+
+	// Check after saying we may sleep, so a push either sees that and
+	// signals or published its line early enough for us to see it.
+	pos := q.tail.Load()
+	if q.cells[pos&q.mask].seq.Load() == pos+1 {
+		return nil, closedCh
+	}
+
+	return nil, q.ready
+}
+
+// len implements the queue interface. It's a snapshot that includes lines
+// being pushed.
+func (q *ringQueue) len() int {
+	tail := q.tail.Load()
+	head := q.head.Load()
+	if head < tail {
+		return 0
+	}
+	return int(head - tail)
+}
+
+// cap implements the queue interface.
+func (q *ringQueue) cap() int {
+	return len(q.cells)
+}
+
+// wake signals whoever waits on the channel without waiting itself. One
+// signal left on the channel is enough since the waiter checks again.
+func wake(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
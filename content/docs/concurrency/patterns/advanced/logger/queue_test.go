@@ -0,0 +1,125 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate the ring buffer and benchmarks comparing it with the
+// channel under many producers.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestRingQueue validates every line pushed by many producers comes out
+// once, in the order each producer pushed them.
+func TestRingQueue(t *testing.T) {
+	const producers = 8
+	const each = 10000
+
+	t.Log("Given the need to share a ring buffer between many producers.")
+	{
+		t.Logf("\tWhen %d producers push %d lines each into a ring of 16.", producers, each)
+		{
+			q := newRingQueue(10)
+			if q.cap() != 16 {
+				t.Fatalf("\t%s\tShould round the capacity up to 16 : %d", failed, q.cap())
+			}
+			t.Logf("\t%s\tShould round the capacity up to 16.", succeed)
+
+			var wg sync.WaitGroup
+			wg.Add(producers)
+			for p := 0; p < producers; p++ {
+				go func(p int) {
+					defer wg.Done()
+					for i := 0; i < each; i++ {
+						q.pushWait(fmt.Sprintf("%d:%d", p, i), nil, nil)
+					}
+				}(p)
+			}
+
+			next := make([]int, producers)
+			for n := 0; n < producers*each; {
+				_, ready := q.recv()
+				<-ready
+
+				for {
+					v, ok := q.pop()
+					if !ok {
+						break
+					}
+					n++
+
+					pv, iv, _ := strings.Cut(v, ":")
+					p, _ := strconv.Atoi(pv)
+					i, _ := strconv.Atoi(iv)
+					if i != next[p] {
+						t.Fatalf("\t%s\tShould receive line %d of producer %d : %s", failed, next[p], p, v)
+					}
+					next[p]++
+				}
+			}
+			wg.Wait()
+
+			if _, ok := q.pop(); ok || q.len() != 0 {
+				t.Fatalf("\t%s\tShould leave the ring empty : %d", failed, q.len())
+			}
+			t.Logf("\t%s\tShould receive every line once and in order.", succeed)
+		}
+	}
+}
+
+// BenchmarkBackends compares the cost of Println for the channel and the
+// ring buffer as the number of goroutines logging at once grows. With
+// drop-newest the callers never wait, with block they wait for the writer.
+//
+//	go test -run none -bench Backends -benchmem
+func BenchmarkBackends(b *testing.B) {
+	policies := []Overflow{DropNewest(), Block()}
+
+	for _, o := range policies {
+		for _, bk := range backends {
+			for _, producers := range []int{1, 10, 100, 1000} {
+				name := fmt.Sprintf("%s/%s/%d", o, strings.ReplaceAll(bk.name, " ", "-"), producers)
+				b.Run(name, func(b *testing.B) {
+					opts := append([]Option{WithOverflow(o)}, bk.opts...)
+					l := New(io.Discard, 1024, opts...)
+					benchProducers(b, l, producers)
+					l.Close(context.Background())
+
+					s := l.Stats()
+					b.ReportMetric(float64(s.Dropped)/float64(b.N), "drops/op")
+				})
+			}
+		}
+	}
+}
+
+// benchProducers splits b.N calls to Println between the goroutines.
+func benchProducers(b *testing.B, l *Logger, producers int) {
+	var wg sync.WaitGroup
+	wg.Add(producers)
+
+	b.ResetTimer()
+	for p := 0; p < producers; p++ {
+		n := b.N / producers
+		if p < b.N%producers {
+			n++
+		}
+
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				l.Println("log data")
+			}
+		}()
+	}
+	wg.Wait()
+	b.StopTimer()
+}
@@ -0,0 +1,236 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// batch collects lines so they can be written to the device with a
+// single Write call.
+type batch struct {
+	buf   bytes.Buffer
+	lines uint64
+}
+
+// add appends the line to the batch.
+func (b *batch) add(v string) {
+	b.buf.WriteString(v)
+	b.buf.WriteByte('\n')
+	b.lines++
+}
+
+// writer is the only goroutine writing to the device. It pulls lines out of
+// the buffer, coalescing whatever is queued into a batch, and writes the
+// batch when it's full, when the flush interval passes, when the buffer is
+// empty and no interval is set, or when asked to flush. On shutdown it
+// drains the buffer unless Close gives up on it first.
+func (l *Logger) writer(w io.Writer) {
+	defer close(l.done)
+
+	var (
+		b        batch
+		reported uint64 // Number of drops we have already told the device about.
+		timer    Timer
+		timeout  <-chan time.Time
+	)
+
+	// write sends the batch to the device and resets it.
+	write := func() {
+		if timer != nil {
+			timer.Stop()
+			timeout = nil
+		}
+
+		if b.lines == 0 {
+			return
+		}
+
+		// Write whatever data we received off the channel to the device
+		// that we passed with one call. Keep track of when the write
+		// started so we can tell when the device is stuck.
+		l.writing.Store(l.clock.Now().UnixNano())
+		buf := b.buf.Bytes()
+		n, err := w.Write(buf)
+		l.writing.Store(0)
+
+		switch {
+		case err != nil:
+
+			// The device may have taken part of the batch before it
+			// failed. End the torn line so the next batch doesn't get
+			// glued onto it.
+			whole := uint64(bytes.Count(buf[:n], []byte{'\n'}))
+			if n > 0 && buf[n-1] != '\n' {
+				w.Write([]byte{'\n'})
+			}
+
+			// The device failed, a disk might be full. The rest of the
+			// lines are lost, so count them with the drops and keep going.
+			l.written.Add(whole)
+			l.failed.Add(b.lines - whole)
+			l.dropped.Add(b.lines - whole)
+			l.dropSince.CompareAndSwap(0, l.clock.Now().UnixNano())
+
+		default:
+			l.written.Add(b.lines)
+
+			// The device is taking writes again. If lines were dropped
+			// while it was stuck, say so to make the gap explicit.
+			reported = l.reportDrops(w, reported)
+		}
+
+		b.buf.Reset()
+		b.lines = 0
+		l.pending.Store(0)
+	}
+
+	// add puts the line in the batch, writing the batch once it's full.
+	// The first line of a batch starts the flush interval.
+	add := func(v string) {
+		b.add(v)
+		l.pending.Store(b.lines)
+
+		switch {
+		case b.lines >= uint64(l.batchSize):
+			write()
+
+		case b.lines == 1 && l.flushEvery > 0:
+			if timer == nil {
+				timer = l.clock.NewTimer(l.flushEvery)
+			} else {
+				timer.Reset(l.flushEvery)
+			}
+			timeout = timer.C()
+		}
+	}
+
+	// abandoned reports whether Close has given up on the device.
+	abandoned := func() bool {
+		select {
+		case <-l.abandon:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// drain takes whatever else is already queued without waiting.
+	drain := func() {
+		for b.lines > 0 {
+			v, ok := l.q.pop()
+			if !ok {
+				return
+			}
+			add(v)
+		}
+	}
+
+	for {
+		if abandoned() {
+			return
+		}
+
+		lines, ready := l.q.recv()
+
+		select {
+		case v := <-lines:
+			add(v)
+			drain()
+
+			// Without an interval there is no reason to hold on to
+			// the batch once the buffer is empty.
+			if l.flushEvery == 0 {
+				write()
+			}
+
+		case <-ready:
+
+			// The line is still in the buffer, and the batch may have
+			// been written since the last one was taken.
+			if v, ok := l.q.pop(); ok {
+				add(v)
+				drain()
+			}
+			if l.flushEvery == 0 {
+				write()
+			}
+
+		case <-timeout:
+			write()
+
+		case done := <-l.flush:
+
+			// Everything in the buffer right now was queued before the
+			// flush was asked for.
+			for n := l.q.len(); n > 0; n-- {
+				v, ok := l.q.pop()
+				if !ok {
+					break
+				}
+				add(v)
+			}
+			write()
+			close(done)
+
+		case <-l.shutdown:
+
+			// Write out everything that is left, checking after every
+			// write whether Close is still willing to wait. Callers that
+			// got past the closed check may still be adding lines, the
+			// buffer is only done once none are left after we look.
+			for {
+				busy := l.active.Load() > 0
+
+				v, ok := l.q.pop()
+				if !ok {
+					if busy {
+						runtime.Gosched()
+						continue
+					}
+					write()
+					return
+				}
+				add(v)
+				if abandoned() {
+					return
+				}
+			}
+		}
+	}
+}
+
+// reportDrops writes a line with the number of drops since the last
+// report, if any. It returns the new number of drops reported.
+func (l *Logger) reportDrops(w io.Writer, reported uint64) uint64 {
+	dropped := l.dropped.Load()
+	if dropped == reported {
+		return reported
+	}
+
+	since := l.clock.Now()
+	if ns := l.dropSince.Swap(0); ns != 0 {
+		since = time.Unix(0, ns)
+	}
+
+	n := dropped - reported
+	line := l.enc.Encode(Entry{
+		Time:    l.clock.Now(),
+		Level:   LevelWarn,
+		Message: fmt.Sprintf("%d messages dropped since %s", n, since.Format(timeFormat)),
+		Fields:  []interface{}{"dropped", n},
+	})
+
+	if _, err := fmt.Fprintln(w, line); err != nil {
+		return reported
+	}
+
+	return dropped
+}
@@ -59,13 +59,13 @@ func main() {
 	var d device
 	// Replace the construction of the logger by using our new logger function
 	// l := log.New(&d, "prefix", 0)
-	l := log.New(&d, grs)
+	l := log.New(&d, grs, log.WithEncoder(log.LogfmtEncoder{}))
 
 	// Generate goroutines, each writing to disk.
 	for i := 0; i < grs; i++ {
 		go func(id int) {
 			for {
-				l.Println(fmt.Sprintf("%d: log data", id))
+				l.Info("log data", "id", id)
 				time.Sleep(10 * time.Millisecond)
 			}
 		}(i)
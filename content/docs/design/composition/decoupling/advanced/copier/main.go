@@ -0,0 +1,72 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Sample program demonstrating how a pipeline file connects any two
+// systems registered with the system package, without changing code.
+//
+//	go run . -config pipeline.yaml
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/cedrickchee/ultimate-go/design/composition/decoupling/advanced/system"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run copies the pipeline and returns the exit code. It's kept apart from
+// main so the deferred calls run before the program exits.
+func run() int {
+	config := flag.String("config", "pipeline.yaml", "pipeline file, .yaml, .yml or .json")
+	every := flag.Duration("progress", time.Second, "how often to print progress, 0 to stay quiet")
+	timeout := flag.Duration("timeout", 0, "time limit for the whole copy, 0 for none")
+	flag.Parse()
+
+	cfg, err := system.LoadConfig(*config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Stop the copy between records on Ctrl-C.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	// Keep count of the copy for the report at the end, printing progress
+	// along the way when asked to.
+	m := system.NewMeter()
+	if *every > 0 {
+		pr := system.NewProgress(os.Stdout, *every)
+		defer pr.Stop()
+		m = pr.Meter
+	}
+
+	n, err := system.Run(ctx, cfg, m)
+
+	s := m.Stats()
+	fmt.Printf("%s: copied %d, dead-lettered %d, failed %d\n", cfg.Name, n, s.DeadLettered, s.Failed)
+
+	if err != io.EOF {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
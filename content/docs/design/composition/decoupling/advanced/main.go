@@ -0,0 +1,85 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Sample program demonstrating how the system package copies data with
+// pulling and storing running at the same time.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cedrickchee/ultimate-go/design/composition/decoupling/advanced/system"
+)
+
+func main() {
+	x := system.Xenia{
+		Host:    "localhost:8000",
+		Timeout: time.Second,
+	}
+
+	p := system.Pillar{
+		Host:    "localhost:9000",
+		Timeout: time.Second,
+	}
+
+	// Give the whole copy a time limit.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Pull with 2 goroutines and store with 2 more, with up to 4 batches
+	// waiting in between.
+	cfg := system.Pipeline{
+		Batch:   3,
+		Pullers: 2,
+		Storers: 2,
+		Queue:   4,
+	}
+
+	// Xenia fails to read now and then, try those pulls again a few
+	// times before giving up on the record.
+	b := system.Backoff{
+		Attempts: 3,
+		Initial:  10 * time.Millisecond,
+		Max:      100 * time.Millisecond,
+	}
+
+	// Xenia and Pillar know nothing about contexts, the adapters check
+	// the context between records for them.
+	xp := system.Retry(system.AdaptPuller(&x), b)
+
+	// Change the records on their way from Xenia to Pillar.
+	xp = system.Through(xp, system.Map(func(d system.Data) (system.Data, error) {
+		d.Line = strings.ToUpper(d.Line)
+		return d, nil
+	}))
+
+	// Print how the copy is doing every second.
+	pr := system.NewProgress(os.Stdout, time.Second)
+	defer pr.Stop()
+
+	n, err := system.CopyPipelined(ctx, xp, system.AdaptStorer(&p), cfg, pr)
+	if err != io.EOF {
+		fmt.Println(err)
+	}
+	fmt.Println("Copied:", n)
+
+	// Copy again, committing the position of the last record stored after
+	// every batch. Running the program again continues after it.
+	cp := system.FileCheckpoint{
+		Path: filepath.Join(os.TempDir(), "xenia.pos"),
+	}
+
+	n, err = system.Resume(ctx, xp, system.AdaptStorer(&p), cfg.Batch, &cp, pr)
+	if err != io.EOF {
+		fmt.Println(err)
+	}
+	fmt.Println("Resumed:", n)
+}
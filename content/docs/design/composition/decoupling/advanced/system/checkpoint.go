@@ -0,0 +1,122 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Seeker declares behavior for a Puller that can continue pulling after a
+// position, so a copy can pick up where it left off.
+type Seeker interface {
+	SeekTo(pos uint64) error
+}
+
+// ErrNotSeekable is returned when a copy needs to resume from a Puller that
+// can't seek.
+var ErrNotSeekable = errors.New("puller can't seek to a position")
+
+// seekTo seeks the value if it's a Seeker.
+func seekTo(v interface{}, pos uint64) error {
+	s, ok := v.(Seeker)
+	if !ok {
+		return ErrNotSeekable
+	}
+	return s.SeekTo(pos)
+}
+
+// =============================================================================
+
+// Checkpoint declares behavior for remembering how far a copy got. Load
+// returns 0 when nothing has been committed yet.
+type Checkpoint interface {
+	Load() (uint64, error)
+	Save(pos uint64) error
+}
+
+// FileCheckpoint is a Checkpoint kept in a file. The position is written
+// to a temporary file that replaces the old one, so a crash never leaves
+// a half written position behind.
+type FileCheckpoint struct {
+	Path string
+}
+
+// Load implements the Checkpoint interface. A missing file means nothing
+// has been committed.
+func (f *FileCheckpoint) Load() (uint64, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	pos, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("checkpoint %s: %w", f.Path, err)
+	}
+	return pos, nil
+}
+
+// Save implements the Checkpoint interface.
+func (f *FileCheckpoint) Save(pos uint64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := fmt.Fprintln(tmp, pos); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// The data must be on disk before the rename makes it the checkpoint.
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// =============================================================================
+
+// Resume knows how to copy data from any System while committing its
+// position to the checkpoint after every stored batch. When the checkpoint
+// already has a position, the Puller must be a Seeker and the copy starts
+// right after it.
+//
+// A crash between storing a batch and saving the checkpoint stores that
+// batch again on the next run, so records are delivered at least once and
+// the Storer must treat a record it already has, by its Seq, as stored.
+// It returns the number of records stored by this run like Copy.
+func Resume(ctx context.Context, p ContextPuller, s ContextStorer, batch int, cp Checkpoint, obs ...Observer) (int, error) {
+	pos, err := cp.Load()
+	if err != nil {
+		return 0, err
+	}
+
+	if pos > 0 {
+		if err := seekTo(p, pos); err != nil {
+			return 0, err
+		}
+	}
+
+	return copyBatches(ctx, p, s, batch, obs, func(last Data) error {
+		return cp.Save(last.Seq)
+	})
+}
@@ -0,0 +1,109 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate a failed copy resumes from its checkpoint.
+package system
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestFileCheckpoint validates positions survive in the file.
+func TestFileCheckpoint(t *testing.T) {
+	t.Log("Given the need to remember how far a copy got.")
+	{
+		cp := FileCheckpoint{Path: filepath.Join(t.TempDir(), "xenia.pos")}
+
+		t.Log("\tWhen nothing has been committed.")
+		{
+			pos, err := cp.Load()
+			if pos != 0 || err != nil {
+				t.Fatalf("\t%s\tShould load position 0 : %d, %v", failed, pos, err)
+			}
+			t.Logf("\t%s\tShould load position 0.", succeed)
+		}
+
+		t.Log("\tWhen positions have been saved.")
+		{
+			for _, pos := range []uint64{3, 6, 9} {
+				if err := cp.Save(pos); err != nil {
+					t.Fatalf("\t%s\tShould save position %d : %v", failed, pos, err)
+				}
+			}
+
+			pos, err := cp.Load()
+			if pos != 9 || err != nil {
+				t.Fatalf("\t%s\tShould load the last position : %d, %v", failed, pos, err)
+			}
+			t.Logf("\t%s\tShould load the last position.", succeed)
+
+			files, _ := filepath.Glob(filepath.Join(filepath.Dir(cp.Path), "*"))
+			if len(files) != 1 {
+				t.Fatalf("\t%s\tShould leave no temporary files : %v", failed, files)
+			}
+			t.Logf("\t%s\tShould leave no temporary files.", succeed)
+		}
+	}
+}
+
+// TestResume validates a copy that failed picks up after the last record
+// it stored.
+func TestResume(t *testing.T) {
+	t.Log("Given the need to resume a copy that failed part way.")
+	{
+		cp := FileCheckpoint{Path: filepath.Join(t.TempDir(), "xenia.pos")}
+		p := counter{n: 20}
+		s := collector{failAt: 8}
+
+		t.Log("\tWhen the Storer fails on the 9th record.")
+		{
+			n, err := Resume(context.Background(), AdaptPuller(&p), AdaptStorer(&s), 3, &cp)
+			if n != 8 || err == nil {
+				t.Fatalf("\t%s\tShould store 8 records and fail : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould store 8 records and fail.", succeed)
+
+			if pos, _ := cp.Load(); pos != 8 {
+				t.Fatalf("\t%s\tShould commit position 8 : %d", failed, pos)
+			}
+			t.Logf("\t%s\tShould commit position 8.", succeed)
+		}
+
+		t.Log("\tWhen the copy runs again from a new Puller.")
+		{
+			p2 := counter{n: 20}
+			s.failAt = 0
+
+			n, err := Resume(context.Background(), AdaptPuller(&p2), AdaptStorer(&s), 3, &cp)
+			if n != 12 || err != io.EOF {
+				t.Fatalf("\t%s\tShould store the other 12 records : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould store the other 12 records.", succeed)
+
+			for i := 0; i < 20; i++ {
+				if s.lines[strconv.Itoa(i)] != 1 {
+					t.Fatalf("\t%s\tShould store record %d once : %v", failed, i, s.lines)
+				}
+			}
+			t.Logf("\t%s\tShould store every record once.", succeed)
+		}
+
+		t.Log("\tWhen the Puller can't seek.")
+		{
+			// Embedding the interface hides the SeekTo method.
+			p3 := struct{ Puller }{&counter{n: 20}}
+
+			n, err := Resume(context.Background(), AdaptPuller(p3), AdaptStorer(&s), 3, &cp)
+			if n != 0 || err != ErrNotSeekable {
+				t.Fatalf("\t%s\tShould refuse to start over : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould refuse to start over.", succeed)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a copy in a pipeline file: where the records come from,
+// the stages they go through and where they are stored. The type of the
+// source, every stage and the sink is a name registered with the registry.
+//
+// Setting Pullers, Storers or Queue runs the copy with CopyPipelined,
+// setting Checkpoint runs it with Resume, otherwise it runs with Copy.
+// Setting DeadLetter stores the records the sink keeps failing on there.
+type Config struct {
+	Name       string            `json:"name" yaml:"name"`
+	Source     Settings          `json:"source" yaml:"source"`
+	Stages     []Settings        `json:"stages" yaml:"stages"`
+	Sink       Settings          `json:"sink" yaml:"sink"`
+	Batch      int               `json:"batch" yaml:"batch"`
+	Pullers    int               `json:"pullers" yaml:"pullers"`
+	Storers    int               `json:"storers" yaml:"storers"`
+	Queue      int               `json:"queue" yaml:"queue"`
+	Checkpoint string            `json:"checkpoint" yaml:"checkpoint"`
+	Retry      *RetryConfig      `json:"retry" yaml:"retry"`
+	DeadLetter *DeadLetterConfig `json:"dead_letter" yaml:"dead_letter"`
+}
+
+// RetryConfig is the Backoff used to retry the source as written in a
+// pipeline file.
+type RetryConfig struct {
+	Attempts int      `json:"attempts" yaml:"attempts"`
+	Initial  Duration `json:"initial" yaml:"initial"`
+	Max      Duration `json:"max" yaml:"max"`
+}
+
+// backoff returns the settings as a Backoff.
+func (r RetryConfig) backoff() Backoff {
+	return Backoff{
+		Attempts: r.Attempts,
+		Initial:  time.Duration(r.Initial),
+		Max:      time.Duration(r.Max),
+	}
+}
+
+// DeadLetterConfig is where records the sink can't store go, as written in
+// a pipeline file, along with the Backoff used to try them again first.
+type DeadLetterConfig struct {
+	Sink        Settings `json:"sink" yaml:"sink"`
+	RetryConfig `yaml:",inline"`
+}
+
+// LoadConfig reads a pipeline file. Files ending in .json are read as JSON,
+// files ending in .yaml or .yml as YAML.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(&cfg)
+
+	default:
+		return Config{}, fmt.Errorf("%s: unknown pipeline file type %q", path, ext)
+	}
+
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the pipeline makes sense before anything is built.
+func (cfg Config) Validate() error {
+	switch {
+	case cfg.Source.Type == "":
+		return errors.New("source has no type")
+	case cfg.Sink.Type == "":
+		return errors.New("sink has no type")
+	case cfg.Batch < 0 || cfg.Pullers < 0 || cfg.Storers < 0 || cfg.Queue < 0:
+		return errors.New("batch, pullers, storers and queue can't be negative")
+	case cfg.Checkpoint != "" && cfg.pipelined():
+		return errors.New("checkpoint can't be used with pullers, storers or queue")
+	case cfg.Sink.Type == "txfile" && cfg.pipelined():
+		return errors.New("txfile sink can't be used with pullers, storers or queue")
+	case cfg.DeadLetter != nil && cfg.DeadLetter.Sink.Type == "":
+		return errors.New("dead letter sink has no type")
+	}
+
+	for i, s := range cfg.Stages {
+		if s.Type == "" {
+			return fmt.Errorf("stage %d has no type", i+1)
+		}
+	}
+
+	return nil
+}
+
+// pipelined reports whether the copy runs with CopyPipelined.
+func (cfg Config) pipelined() bool {
+	return cfg.Pullers > 0 || cfg.Storers > 0 || cfg.Queue > 0
+}
+
+// =============================================================================
+
+// Duration is a time.Duration that is written as "5s" in a pipeline file.
+type Duration time.Duration
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// =============================================================================
+
+// Run builds the source, stages and sinks of the pipeline from the registry
+// and copies the records like Copy, CopyPipelined or Resume would. The
+// source and sinks are closed once the copy is over if they are io.Closers.
+// Observers that are DeadLetterObservers are told about dead letters.
+func Run(ctx context.Context, cfg Config, obs ...Observer) (int, error) {
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+
+	src, err := NewPuller(cfg.Source)
+	if err != nil {
+		return 0, fmt.Errorf("source: %w", err)
+	}
+	defer closeIfCloser(src)
+
+	sink, err := NewStorer(cfg.Sink)
+	if err != nil {
+		return 0, fmt.Errorf("sink: %w", err)
+	}
+	defer closeIfCloser(sink)
+
+	var s ContextStorer = sink
+	if dl := cfg.DeadLetter; dl != nil {
+		dlSink, err := NewStorer(dl.Sink)
+		if err != nil {
+			return 0, fmt.Errorf("dead letter sink: %w", err)
+		}
+		defer closeIfCloser(dlSink)
+
+		s = WithDeadLetter(sink, dlSink, dl.backoff(), obs...)
+	}
+
+	stages := make([]Stage, len(cfg.Stages))
+	for i, s := range cfg.Stages {
+		if stages[i], err = NewStage(s); err != nil {
+			return 0, fmt.Errorf("stage %d: %w", i+1, err)
+		}
+	}
+
+	p := src
+	if r := cfg.Retry; r != nil {
+		p = Retry(p, r.backoff())
+	}
+	if len(stages) > 0 {
+		p = Through(p, stages...)
+	}
+
+	batch := cfg.Batch
+	if batch < 1 {
+		batch = 100
+	}
+
+	switch {
+	case cfg.pipelined():
+		pl := Pipeline{
+			Batch:   batch,
+			Pullers: cfg.Pullers,
+			Storers: cfg.Storers,
+			Queue:   cfg.Queue,
+		}
+		return CopyPipelined(ctx, p, s, pl, obs...)
+
+	case cfg.Checkpoint != "":
+		return Resume(ctx, p, s, batch, &FileCheckpoint{Path: cfg.Checkpoint}, obs...)
+
+	default:
+		return Copy(ctx, p, s, batch, obs...)
+	}
+}
+
+// closeIfCloser closes the value if it knows how to.
+func closeIfCloser(v interface{}) {
+	if c, ok := v.(io.Closer); ok {
+		c.Close()
+	}
+}
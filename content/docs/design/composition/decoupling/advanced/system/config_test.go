@@ -0,0 +1,205 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate pipeline files and the registry.
+package system
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadConfig validates the same pipeline reads the same from YAML
+// and JSON.
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"pipeline.yaml": `
+name: test
+source: {type: file, host: in.txt, timeout: 2s}
+stages:
+  - type: filter
+    options: {match: "^a"}
+sink: {type: http, host: "http://localhost:9000"}
+batch: 10
+retry: {attempts: 3, initial: 10ms, max: 1s}
+dead_letter:
+  sink: {type: file, host: dead.txt}
+  attempts: 2
+  initial: 5ms
+`,
+		"pipeline.json": `{
+	"name": "test",
+	"source": {"type": "file", "host": "in.txt", "timeout": "2s"},
+	"stages": [{"type": "filter", "options": {"match": "^a"}}],
+	"sink": {"type": "http", "host": "http://localhost:9000"},
+	"batch": 10,
+	"retry": {"attempts": 3, "initial": "10ms", "max": "1s"},
+	"dead_letter": {"sink": {"type": "file", "host": "dead.txt"}, "attempts": 2, "initial": "5ms"}
+}`,
+	}
+
+	t.Log("Given the need to read pipeline files.")
+	{
+		for name, body := range files {
+			t.Logf("\tWhen reading %s.", name)
+			{
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				cfg, err := LoadConfig(path)
+				if err != nil {
+					t.Fatalf("\t%s\tShould read the file : %v", failed, err)
+				}
+				t.Logf("\t%s\tShould read the file.", succeed)
+
+				switch {
+				case cfg.Name != "test",
+					cfg.Source.Type != "file", cfg.Source.Host != "in.txt",
+					time.Duration(cfg.Source.Timeout) != 2*time.Second,
+					len(cfg.Stages) != 1, cfg.Stages[0].Options["match"] != "^a",
+					cfg.Sink.Type != "http", cfg.Batch != 10,
+					cfg.Retry == nil, time.Duration(cfg.Retry.Initial) != 10*time.Millisecond,
+					cfg.DeadLetter == nil, cfg.DeadLetter.Sink.Host != "dead.txt",
+					cfg.DeadLetter.Attempts != 2, time.Duration(cfg.DeadLetter.Initial) != 5*time.Millisecond:
+					t.Fatalf("\t%s\tShould read every setting : %+v", failed, cfg)
+				}
+				t.Logf("\t%s\tShould read every setting.", succeed)
+			}
+		}
+
+		t.Log("\tWhen reading files that don't make sense.")
+		{
+			bad := map[string]string{
+				"unknown.yaml":  "source: {type: file}\nsink: {type: file}\nbatchsize: 10\n",
+				"duration.json": `{"source": {"type": "file", "timeout": "soon"}, "sink": {"type": "file"}}`,
+				"nosink.yaml":   "source: {type: file}\n",
+				"both.yaml":     "source: {type: file}\nsink: {type: file}\npullers: 2\ncheckpoint: pos\n",
+				"txfile.yaml":   "source: {type: file}\nsink: {type: txfile}\npullers: 2\n",
+				"pipeline.toml": "",
+			}
+			for name, body := range bad {
+				path := filepath.Join(dir, name)
+				os.WriteFile(path, []byte(body), 0644)
+
+				if _, err := LoadConfig(path); err == nil {
+					t.Fatalf("\t%s\tShould fail to read %s.", failed, name)
+				}
+			}
+			t.Logf("\t%s\tShould fail to read any of them.", succeed)
+		}
+	}
+}
+
+// TestRun validates a pipeline built from its settings copies records.
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "xenia.txt")
+	if err := os.WriteFile(src, []byte("a1\nb2\na1\na3\nc4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Given the need to run a copy from a pipeline file.")
+	{
+		modes := []struct {
+			name string
+			cfg  func(cfg *Config)
+		}{
+			{"with Copy", func(cfg *Config) {}},
+			{"with CopyPipelined", func(cfg *Config) { cfg.Pullers = 2 }},
+			{"with Resume", func(cfg *Config) { cfg.Checkpoint = filepath.Join(dir, "pos") }},
+		}
+
+		for i, m := range modes {
+			t.Logf("\tWhen copying file to file through a filter, dedupe and map %s.", m.name)
+			{
+				dst := filepath.Join(dir, strings.ReplaceAll(m.name, " ", "-"))
+
+				cfg := Config{
+					Source: Settings{Type: "file", Host: src},
+					Stages: []Settings{
+						{Type: "filter", Options: map[string]string{"match": "^a"}},
+						{Type: "dedupe"},
+						{Type: "map", Options: map[string]string{"case": "upper", "prefix": "> "}},
+					},
+					Sink:  Settings{Type: "file", Host: dst},
+					Batch: 2,
+				}
+				m.cfg(&cfg)
+
+				n, err := Run(context.Background(), cfg)
+				if n != 2 || err != io.EOF {
+					t.Fatalf("\t%s\tShould copy 2 records : %d, %v", failed, n, err)
+				}
+				t.Logf("\t%s\tShould copy 2 records.", succeed)
+
+				data, _ := os.ReadFile(dst)
+				if string(data) != "> A1\n> A3\n" {
+					t.Fatalf("\t%s\tShould write the changed lines : %q", failed, data)
+				}
+				t.Logf("\t%s\tShould write the changed lines.", succeed)
+
+				if i == 2 {
+					n, err := Run(context.Background(), cfg)
+					if n != 0 || err != io.EOF {
+						t.Fatalf("\t%s\tShould copy nothing running again : %d, %v", failed, n, err)
+					}
+					t.Logf("\t%s\tShould copy nothing running again.", succeed)
+				}
+			}
+		}
+
+		t.Log("\tWhen the pipeline names a system that isn't registered.")
+		{
+			cfg := Config{
+				Source: Settings{Type: "file", Host: src},
+				Sink:   Settings{Type: "mongo"},
+			}
+
+			_, err := Run(context.Background(), cfg)
+			if err == nil || !strings.Contains(err.Error(), `unknown storer "mongo"`) {
+				t.Fatalf("\t%s\tShould fail on the unknown sink : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould fail on the unknown sink : %v", succeed, err)
+		}
+
+		t.Log("\tWhen a system is registered from outside the package.")
+		{
+			// Tests may run more than once in the same process.
+			name := "memory-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+			RegisterStorer(name, func(s Settings) (ContextStorer, error) {
+				return &MemoryStorer{}, nil
+			})
+
+			cfg := Config{
+				Source: Settings{Type: "file", Host: src},
+				Sink:   Settings{Type: name},
+			}
+
+			n, err := Run(context.Background(), cfg)
+			if n != 5 || err != io.EOF {
+				t.Fatalf("\t%s\tShould copy to it : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy to it.", succeed)
+
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("\t%s\tShould panic registering the name twice.", failed)
+				}
+				t.Logf("\t%s\tShould panic registering the name twice.", succeed)
+			}()
+			RegisterStorer(name, nil)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import "context"
+
+// ContextPuller declares behavior for pulling data that can be cancelled
+// or given a deadline.
+type ContextPuller interface {
+	PullContext(ctx context.Context, d *Data) error
+}
+
+// ContextStorer declares behavior for storing data that can be cancelled
+// or given a deadline.
+type ContextStorer interface {
+	StoreContext(ctx context.Context, d *Data) error
+}
+
+// =============================================================================
+
+// AdaptPuller returns a ContextPuller for any Puller. A Puller that already
+// knows about contexts is returned as is. For the others the context is
+// checked before every pull, but a pull in progress can't be interrupted.
+func AdaptPuller(p Puller) ContextPuller {
+	if cp, ok := p.(ContextPuller); ok {
+		return cp
+	}
+	return puller{p}
+}
+
+// puller adapts a Puller to the ContextPuller interface.
+type puller struct {
+	p Puller
+}
+
+// PullContext implements the ContextPuller interface.
+func (a puller) PullContext(ctx context.Context, d *Data) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.p.Pull(d)
+}
+
+// SeekTo implements the Seeker interface when the Puller does.
+func (a puller) SeekTo(pos uint64) error {
+	return seekTo(a.p, pos)
+}
+
+// AdaptStorer returns a ContextStorer for any Storer. A Storer that already
+// knows about contexts is returned as is. For the others the context is
+// checked before every store, but a store in progress can't be interrupted.
+func AdaptStorer(s Storer) ContextStorer {
+	if cs, ok := s.(ContextStorer); ok {
+		return cs
+	}
+	return storer{s}
+}
+
+// storer adapts a Storer to the ContextStorer interface.
+type storer struct {
+	s Storer
+}
+
+// StoreContext implements the ContextStorer interface.
+func (a storer) StoreContext(ctx context.Context, d *Data) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.s.Store(d)
+}
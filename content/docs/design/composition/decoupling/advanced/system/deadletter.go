@@ -0,0 +1,163 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// DeadLetter represents a record that could not be stored, along with the
+// last error and the number of attempts made.
+type DeadLetter struct {
+	Data
+	Err      string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+// ParseDeadLetter reads back a record written to a dead-letter Storer.
+func ParseDeadLetter(d Data) (DeadLetter, error) {
+	var dl DeadLetter
+	if err := json.Unmarshal([]byte(d.Line), &dl); err != nil {
+		return DeadLetter{}, fmt.Errorf("record %d is not a dead letter: %w", d.Seq, err)
+	}
+	return dl, nil
+}
+
+// DeadLetterObserver is implemented by observers that also want to know
+// about records sent to a dead-letter Storer.
+type DeadLetterObserver interface {
+	DeadLettered(n int, err error)
+}
+
+// deadLettered tells the observers that care about records sent to a
+// dead-letter Storer.
+func (o observers) deadLettered(n int, err error) {
+	for _, ob := range o {
+		if dlo, ok := ob.(DeadLetterObserver); ok {
+			dlo.DeadLettered(n, err)
+		}
+	}
+}
+
+// =============================================================================
+
+// DeadLetterStorer is a Storer that doesn't let a record it can't store
+// stop the copy.
+type DeadLetterStorer struct {
+	s    ContextStorer
+	dl   ContextStorer
+	b    Backoff
+	obs  observers
+	dead atomic.Int64
+}
+
+// WithDeadLetter decorates the Storer so a record that fails to store is
+// tried again, backing off between attempts, and once the attempts run out
+// is stored in dl as a DeadLetter instead. Errors marked Permanent are not
+// tried again. The copy counts dead-lettered records as stored, it's done
+// with them, and the observers are told about them.
+//
+// When the Storer is a TxStorer, a batch is first stored in a transaction
+// the way it would be without the decorator. Only when that fails are the
+// records stored one at a time, so one bad record doesn't take the rest of
+// its batch with it. Any other Storer gets one record at a time, even a
+// BatchStorer, since a batch it fails part way may have stored some of the
+// records already. Only the context being done, or dl failing too, is
+// returned as an error.
+func WithDeadLetter(s, dl ContextStorer, b Backoff, obs ...Observer) *DeadLetterStorer {
+	return &DeadLetterStorer{
+		s:   s,
+		dl:  dl,
+		b:   b.withDefaults(),
+		obs: obs,
+	}
+}
+
+// Store implements the Storer interface.
+func (dls *DeadLetterStorer) Store(d *Data) error {
+	return dls.StoreContext(context.Background(), d)
+}
+
+// StoreContext implements the ContextStorer interface.
+func (dls *DeadLetterStorer) StoreContext(ctx context.Context, d *Data) error {
+	attempt := 1
+	for ; ; attempt++ {
+		err := dls.s.StoreContext(ctx, d)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if attempt == dls.b.Attempts || isPermanent(err) {
+			return dls.deadLetter(ctx, d, err, attempt)
+		}
+
+		if err := dls.b.wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// storeBatch implements the batchDecorator interface. A transaction that
+// fails leaves nothing behind, so its batch is stored again one record at
+// a time.
+func (dls *DeadLetterStorer) storeBatch(ctx context.Context, data []Data) (int, error) {
+	if ts, ok := dls.s.(TxStorer); ok {
+		if n, err := storeTx(ctx, ts, data); err == nil {
+			return n, nil
+		}
+	}
+
+	for i := range data {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := dls.StoreContext(ctx, &data[i]); err != nil {
+			return i, err
+		}
+	}
+
+	return len(data), nil
+}
+
+// deadLetter stores the record in the dead-letter Storer.
+func (dls *DeadLetterStorer) deadLetter(ctx context.Context, d *Data, cause error, attempts int) error {
+	line, err := json.Marshal(DeadLetter{Data: *d, Err: cause.Error(), Attempts: attempts})
+	if err != nil {
+		return Permanent(err)
+	}
+
+	if err := dls.dl.StoreContext(ctx, &Data{Seq: d.Seq, Line: string(line)}); err != nil {
+		return Permanent(fmt.Errorf("dead-letter record %d after %v: %w", d.Seq, cause, err))
+	}
+
+	dls.dead.Add(1)
+	dls.obs.deadLettered(1, cause)
+	return nil
+}
+
+// DeadLettered returns the number of records sent to the dead-letter
+// Storer so far.
+func (dls *DeadLetterStorer) DeadLettered() int {
+	return int(dls.dead.Load())
+}
+
+// isPermanent reports whether the error was marked as one that trying
+// again won't fix. Unlike IsTransient, errors that were never classified
+// don't count.
+func isPermanent(err error) bool {
+	var serr *Error
+	if errors.As(err, &serr) {
+		return serr.Kind == KindPermanent
+	}
+	return false
+}
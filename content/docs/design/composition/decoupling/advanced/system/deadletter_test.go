@@ -0,0 +1,214 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate records that fail to store go to the dead-letter
+// Storer without stopping the copy.
+package system
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// picky is a Storer that fails to store the records in fail, as many
+// times as the value says, or every time if it's negative.
+type picky struct {
+	MemoryStorer
+	mu    sync.Mutex
+	fail  map[string]int
+	err   error
+	calls map[string]int
+}
+
+// StoreContext implements the ContextStorer interface.
+func (p *picky) StoreContext(ctx context.Context, d *Data) error {
+	p.mu.Lock()
+	if p.calls == nil {
+		p.calls = make(map[string]int)
+	}
+	p.calls[d.Line]++
+	n, ok := p.fail[d.Line]
+	fail := ok && (n < 0 || p.calls[d.Line] <= n)
+	p.mu.Unlock()
+
+	if fail {
+		return p.err
+	}
+	return p.MemoryStorer.StoreContext(ctx, d)
+}
+
+// brokenTx is a faultyTx that fails to store the record on its own too.
+type brokenTx struct {
+	faultyTx
+}
+
+// StoreContext implements the ContextStorer interface.
+func (b brokenTx) StoreContext(ctx context.Context, d *Data) error {
+	if d.Seq == b.at {
+		return errors.New("pillar is full")
+	}
+	return b.faultyTx.StoreContext(ctx, d)
+}
+
+// halfBatch is a BatchStorer that stores the first half of a batch and
+// fails the rest.
+type halfBatch struct {
+	MemoryStorer
+}
+
+// StoreBatch implements the BatchStorer interface.
+func (h *halfBatch) StoreBatch(ctx context.Context, data []Data) error {
+	for i := range data[:len(data)/2] {
+		h.StoreContext(ctx, &data[i])
+	}
+	return errors.New("disk is full")
+}
+
+// TestDeadLetter validates failed records are tried again, then go to the
+// dead-letter Storer while the copy keeps going.
+func TestDeadLetter(t *testing.T) {
+	b := Backoff{Attempts: 3, Initial: time.Microsecond, Max: 10 * time.Microsecond}
+
+	t.Log("Given the need to keep copying when records fail to store.")
+	{
+		t.Log("\tWhen record 3 always fails and record 5 fails twice.")
+		{
+			s := picky{
+				fail: map[string]int{"3": -1, "5": 2},
+				err:  errors.New("pillar is full"),
+			}
+			var dl MemoryStorer
+			m := NewMeter()
+
+			p := counter{n: 10}
+			dls := WithDeadLetter(&s, &dl, b, m)
+
+			n, err := Copy(context.Background(), AdaptPuller(&p), dls, 4, m)
+			if n != 10 || err != io.EOF {
+				t.Fatalf("\t%s\tShould finish the copy : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould finish the copy.", succeed)
+
+			if got := len(s.Records()); got != 9 || s.calls["3"] != 3 || s.calls["5"] != 3 {
+				t.Fatalf("\t%s\tShould store 9 records, trying 3 and 5 three times : %d, %v", failed, got, s.calls)
+			}
+			t.Logf("\t%s\tShould store 9 records, trying 3 and 5 three times.", succeed)
+
+			dead := dl.Records()
+			if len(dead) != 1 {
+				t.Fatalf("\t%s\tShould dead-letter 1 record : %d", failed, len(dead))
+			}
+			letter, err := ParseDeadLetter(dead[0])
+			if err != nil || letter.Line != "3" || letter.Seq != 4 || letter.Attempts != 3 || letter.Err != "pillar is full" {
+				t.Fatalf("\t%s\tShould dead-letter record 3 with its error and attempts : %+v, %v", failed, letter, err)
+			}
+			t.Logf("\t%s\tShould dead-letter record 3 with its error and attempts.", succeed)
+
+			if dls.DeadLettered() != 1 || m.Stats().DeadLettered != 1 {
+				t.Fatalf("\t%s\tShould report 1 dead letter : %d, %d", failed, dls.DeadLettered(), m.Stats().DeadLettered)
+			}
+			t.Logf("\t%s\tShould report 1 dead letter.", succeed)
+		}
+
+		t.Log("\tWhen a record fails with a permanent error.")
+		{
+			s := picky{
+				fail: map[string]int{"2": -1},
+				err:  Permanent(errors.New("record is too large")),
+			}
+			var dl MemoryStorer
+
+			p := counter{n: 4}
+			n, err := Copy(context.Background(), AdaptPuller(&p), WithDeadLetter(&s, &dl, b), 4)
+			if n != 4 || err != io.EOF || s.calls["2"] != 1 || len(dl.Records()) != 1 {
+				t.Fatalf("\t%s\tShould dead-letter it without trying again : %d, %v, %d", failed, n, err, s.calls["2"])
+			}
+			t.Logf("\t%s\tShould dead-letter it without trying again.", succeed)
+		}
+
+		t.Log("\tWhen the dead-letter Storer fails too.")
+		{
+			s := picky{
+				fail: map[string]int{"2": -1},
+				err:  errors.New("pillar is full"),
+			}
+			dl := picky{
+				fail: map[string]int{},
+				err:  errors.New("dead letters are full"),
+			}
+			dl.fail[`{"seq":3,"line":"2","error":"pillar is full","attempts":3}`] = -1
+
+			p := counter{n: 4}
+			n, err := Copy(context.Background(), AdaptPuller(&p), WithDeadLetter(&s, &dl, b), 4)
+			if n != 2 || err == nil || err == io.EOF || IsTransient(err) {
+				t.Fatalf("\t%s\tShould stop the copy with a permanent error : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould stop the copy with a permanent error : %v", succeed, err)
+		}
+
+		t.Log("\tWhen the Storer stores a batch in a transaction.")
+		{
+			fs := FileTxStorer{Dir: t.TempDir()}
+			s := brokenTx{faultyTx{FileTxStorer: &fs, at: 3}}
+			var dl MemoryStorer
+
+			p := counter{n: 10}
+			n, err := Copy(context.Background(), AdaptPuller(&p), WithDeadLetter(s, &dl, b), 4)
+			if n != 10 || err != io.EOF || len(dl.Records()) != 1 {
+				t.Fatalf("\t%s\tShould finish the copy and dead-letter 1 record : %d, %v, %d", failed, n, err, len(dl.Records()))
+			}
+			t.Logf("\t%s\tShould finish the copy and dead-letter 1 record.", succeed)
+
+			data, err := fs.Records()
+			if err != nil || len(data) != 9 {
+				t.Fatalf("\t%s\tShould store 9 records : %d, %v", failed, len(data), err)
+			}
+			t.Logf("\t%s\tShould store 9 records.", succeed)
+
+			// Records 1, 2 and 4 go one at a time, the other two batches
+			// as a whole.
+			names, err := filepath.Glob(filepath.Join(fs.Dir, "*"+batchExt))
+			if err != nil || len(names) != 5 {
+				t.Fatalf("\t%s\tShould only store the failed batch a record at a time : %d, %v", failed, len(names), err)
+			}
+			t.Logf("\t%s\tShould only store the failed batch a record at a time.", succeed)
+		}
+
+		t.Log("\tWhen the Storer can fail part way through a batch.")
+		{
+			var s halfBatch
+
+			p := counter{n: 10}
+			n, err := Copy(context.Background(), AdaptPuller(&p), WithDeadLetter(&s, &MemoryStorer{}, b), 4)
+			if n != 10 || err != io.EOF || len(s.Records()) != 10 {
+				t.Fatalf("\t%s\tShould store every record once : %d, %v, %d", failed, n, err, len(s.Records()))
+			}
+			t.Logf("\t%s\tShould store every record once.", succeed)
+		}
+
+		t.Log("\tWhen using the pipelined copy.")
+		{
+			s := picky{
+				fail: map[string]int{"10": -1, "20": -1, "30": 1},
+				err:  errors.New("pillar is full"),
+			}
+			var dl MemoryStorer
+			m := NewMeter()
+
+			p := counter{n: 100}
+			cfg := Pipeline{Batch: 7, Pullers: 2, Storers: 4, Queue: 2}
+			n, err := CopyPipelined(context.Background(), AdaptPuller(&p), WithDeadLetter(&s, &dl, b, m), cfg)
+			if n != 100 || err != io.EOF || len(s.Records()) != 98 || m.Stats().DeadLettered != 2 {
+				t.Fatalf("\t%s\tShould store 98 records and dead-letter 2 : %d, %v, %d, %d", failed, n, err, len(s.Records()), m.Stats().DeadLettered)
+			}
+			t.Logf("\t%s\tShould store 98 records and dead-letter 2.", succeed)
+		}
+	}
+}
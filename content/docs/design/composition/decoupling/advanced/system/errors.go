@@ -0,0 +1,68 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind identifies whether trying again can fix a failure. The end of
+// the data is not a failure, it's reported with io.EOF.
+type ErrorKind int
+
+// Set of error categories a system can fail with.
+const (
+	KindTransient ErrorKind = iota + 1
+	KindPermanent
+)
+
+// String returns the name of the error category.
+func (k ErrorKind) String() string {
+	switch k {
+	case KindTransient:
+		return "transient"
+	case KindPermanent:
+		return "permanent"
+	}
+	return "unknown"
+}
+
+// Error is returned when a system fails to pull or store data.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s error: %v", e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Transient marks the error as one that may go away if we try again.
+func Transient(err error) error {
+	return &Error{Kind: KindTransient, Err: err}
+}
+
+// Permanent marks the error as one that trying again won't fix.
+func Permanent(err error) error {
+	return &Error{Kind: KindPermanent, Err: err}
+}
+
+// IsTransient reports whether trying again may fix the error. Errors that
+// were never classified are treated as permanent.
+func IsTransient(err error) bool {
+	var serr *Error
+	if errors.As(err, &serr) {
+		return serr.Kind == KindTransient
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// deadline sets the earlier of the context's deadline and the timeout on
+// the file. Regular files never block and don't support deadlines, only
+// pipes and the like do.
+func deadline(ctx context.Context, timeout time.Duration, set func(time.Time) error) error {
+	var t time.Time
+	if timeout > 0 {
+		t = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (t.IsZero() || d.Before(t)) {
+		t = d
+	}
+
+	if err := set(t); err != nil && !errors.Is(err, os.ErrNoDeadline) {
+		return err
+	}
+	return nil
+}
+
+// fileError classifies an error from a file. Running out of time may go
+// away if we try again, anything else won't.
+func fileError(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return Transient(err)
+	}
+	return Permanent(err)
+}
+
+// =============================================================================
+
+// FilePuller is a system that pulls a record for every line of a file.
+// The position of a record is its line number. Timeout limits how long a
+// pull waits for a line from a pipe.
+type FilePuller struct {
+	Path    string
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	f       *os.File
+	r       *bufio.Reader
+	partial string // Start of a line a pull ran out of time reading.
+	pos     uint64 // Position of the last line pulled.
+	skip    uint64 // Lines to skip before pulling, set by SeekTo.
+}
+
+// Pull implements the Puller interface.
+func (fp *FilePuller) Pull(d *Data) error {
+	return fp.PullContext(context.Background(), d)
+}
+
+// PullContext implements the ContextPuller interface.
+func (fp *FilePuller) PullContext(ctx context.Context, d *Data) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	if fp.f == nil {
+		f, err := os.Open(fp.Path)
+		if err != nil {
+			return Permanent(err)
+		}
+		fp.f = f
+		fp.r = bufio.NewReader(f)
+	}
+
+	if err := deadline(ctx, fp.Timeout, fp.f.SetReadDeadline); err != nil {
+		return Permanent(err)
+	}
+
+	for {
+		line, err := fp.readLine()
+		if err != nil {
+			return err
+		}
+
+		fp.pos++
+		if fp.pos <= fp.skip {
+			continue
+		}
+
+		d.Seq = fp.pos
+		d.Line = line
+		return nil
+	}
+}
+
+// readLine reads the next line without its line ending. A line that is
+// cut short by the deadline is kept for the next pull.
+func (fp *FilePuller) readLine() (string, error) {
+	line, err := fp.r.ReadString('\n')
+	line = fp.partial + line
+	fp.partial = ""
+
+	switch {
+	case err == io.EOF && line != "":
+
+		// The last line doesn't need a line ending.
+
+	case err == io.EOF:
+		return "", io.EOF
+
+	case err != nil:
+		fp.partial = line
+		return "", fileError(err)
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// SeekTo implements the Seeker interface.
+func (fp *FilePuller) SeekTo(pos uint64) error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	// Lines can't be read twice, so going back means starting over.
+	if pos < fp.pos && fp.f != nil {
+		fp.f.Close()
+		fp.f = nil
+		fp.partial = ""
+		fp.pos = 0
+	}
+
+	fp.skip = pos
+	return nil
+}
+
+// Close closes the file.
+func (fp *FilePuller) Close() error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	if fp.f == nil {
+		return nil
+	}
+
+	err := fp.f.Close()
+	fp.f = nil
+	return err
+}
+
+// =============================================================================
+
+// FileStorer is a system that stores every record as a line at the end
+// of a file. Timeout limits how long a store waits to write to a pipe.
+type FileStorer struct {
+	Path    string
+	Timeout time.Duration
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Store implements the Storer interface.
+func (fs *FileStorer) Store(d *Data) error {
+	return fs.StoreContext(context.Background(), d)
+}
+
+// StoreContext implements the ContextStorer interface.
+func (fs *FileStorer) StoreContext(ctx context.Context, d *Data) error {
+	return fs.StoreBatch(ctx, []Data{*d})
+}
+
+// StoreBatch implements the BatchStorer interface by writing the batch
+// to the file with one write.
+func (fs *FileStorer) StoreBatch(ctx context.Context, data []Data) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, d := range data {
+		b.WriteString(d.Line)
+		b.WriteByte('\n')
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f == nil {
+		f, err := os.OpenFile(fs.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return Permanent(err)
+		}
+		fs.f = f
+	}
+
+	if err := deadline(ctx, fs.Timeout, fs.f.SetWriteDeadline); err != nil {
+		return Permanent(err)
+	}
+
+	if _, err := io.WriteString(fs.f, b.String()); err != nil {
+		return fileError(err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (fs *FileStorer) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f == nil {
+		return nil
+	}
+
+	err := fs.f.Close()
+	fs.f = nil
+	return err
+}
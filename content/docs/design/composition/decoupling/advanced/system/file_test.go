@@ -0,0 +1,104 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate the file systems.
+package system
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileCopy validates lines are copied from one file to another.
+func TestFileCopy(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "xenia.txt")
+	dst := filepath.Join(dir, "pillar.txt")
+
+	// Line endings of both kinds and no line ending at the end.
+	if err := os.WriteFile(src, []byte("a\nb\r\n\nd"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Given the need to copy the lines of a file.")
+	{
+		t.Log("\tWhen copying a file with 4 lines.")
+		{
+			p := FilePuller{Path: src}
+			defer p.Close()
+			s := FileStorer{Path: dst}
+			defer s.Close()
+
+			n, err := Copy(context.Background(), &p, &s, 3)
+			if n != 4 || err != io.EOF {
+				t.Fatalf("\t%s\tShould copy 4 records : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy 4 records.", succeed)
+
+			data, _ := os.ReadFile(dst)
+			if string(data) != "a\nb\n\nd\n" {
+				t.Fatalf("\t%s\tShould write every line : %q", failed, data)
+			}
+			t.Logf("\t%s\tShould write every line.", succeed)
+		}
+
+		t.Log("\tWhen seeking back to after the 2nd line.")
+		{
+			p := FilePuller{Path: src}
+			defer p.Close()
+
+			var d Data
+			for i := 0; i < 3; i++ {
+				p.Pull(&d)
+			}
+			p.SeekTo(2)
+
+			if err := p.Pull(&d); err != nil || d.Seq != 3 || d.Line != "" {
+				t.Fatalf("\t%s\tShould pull line 3 : %+v, %v", failed, d, err)
+			}
+			if err := p.Pull(&d); err != nil || d.Seq != 4 || d.Line != "d" {
+				t.Fatalf("\t%s\tShould pull line 4 : %+v, %v", failed, d, err)
+			}
+			t.Logf("\t%s\tShould pull lines 3 and 4.", succeed)
+		}
+
+		t.Log("\tWhen the file doesn't exist.")
+		{
+			p := FilePuller{Path: filepath.Join(dir, "missing.txt")}
+
+			var d Data
+			if err := p.Pull(&d); err == nil || IsTransient(err) {
+				t.Fatalf("\t%s\tShould fail with a permanent error : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould fail with a permanent error.", succeed)
+		}
+	}
+}
+
+// TestMemoryCopy validates records are copied in memory.
+func TestMemoryCopy(t *testing.T) {
+	t.Log("Given the need to copy records in memory.")
+	{
+		t.Log("\tWhen copying 3 records.")
+		{
+			p := NewMemoryPuller("a", "b", "c")
+			var s MemoryStorer
+
+			n, err := Copy(context.Background(), p, &s, 2)
+			if n != 3 || err != io.EOF {
+				t.Fatalf("\t%s\tShould copy 3 records : %d, %v", failed, n, err)
+			}
+
+			got := s.Records()
+			if len(got) != 3 || got[2] != (Data{Seq: 3, Line: "c"}) {
+				t.Fatalf("\t%s\tShould store the records : %+v", failed, got)
+			}
+			t.Logf("\t%s\tShould store the records.", succeed)
+		}
+	}
+}
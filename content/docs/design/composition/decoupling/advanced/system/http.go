@@ -0,0 +1,204 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// send makes the request with the timeout and classifies what went wrong.
+// Running out of time, failing to connect and the server being in trouble
+// may go away if we try again, the caller giving up won't.
+func send(ctx context.Context, client *http.Client, timeout time.Duration, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	rctx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		rctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resp, err := client.Do(req.WithContext(rctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, Transient(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, Transient(err)
+	}
+
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		return body, nil
+
+	case code >= 500, code == http.StatusTooManyRequests, code == http.StatusRequestTimeout:
+		return nil, Transient(fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status))
+
+	default:
+		return nil, Permanent(fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status))
+	}
+}
+
+// =============================================================================
+
+// HTTPPuller is a system that pulls records from a JSON endpoint a page at
+// a time. It asks for the records after the last position it pulled:
+//
+//	GET /records?after=42&limit=100
+//
+// and expects a JSON array of records back, empty once there are no more:
+//
+//	[{"seq":43,"line":"Data"},{"seq":44,"line":"Data"}]
+//
+// Timeout limits how long fetching a page can take.
+type HTTPPuller struct {
+	URL      string
+	Timeout  time.Duration
+	PageSize int          // Records asked for at a time, 100 when zero.
+	Client   *http.Client // http.DefaultClient when nil.
+
+	mu    sync.Mutex
+	page  []Data
+	after uint64 // Position of the last record pulled.
+}
+
+// Pull implements the Puller interface.
+func (hp *HTTPPuller) Pull(d *Data) error {
+	return hp.PullContext(context.Background(), d)
+}
+
+// PullContext implements the ContextPuller interface.
+func (hp *HTTPPuller) PullContext(ctx context.Context, d *Data) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+
+	if len(hp.page) == 0 {
+		page, err := hp.fetch(ctx)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return io.EOF
+		}
+		hp.page = page
+	}
+
+	*d = hp.page[0]
+	hp.page = hp.page[1:]
+	hp.after = d.Seq
+	return nil
+}
+
+// fetch gets the page of records after the last one pulled.
+func (hp *HTTPPuller) fetch(ctx context.Context) ([]Data, error) {
+	u, err := url.Parse(hp.URL)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+
+	limit := hp.PageSize
+	if limit < 1 {
+		limit = 100
+	}
+
+	q := u.Query()
+	q.Set("after", strconv.FormatUint(hp.after, 10))
+	q.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	body, err := send(ctx, hp.Client, hp.Timeout, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var page []Data
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, Permanent(fmt.Errorf("GET %s: %w", u, err))
+	}
+	return page, nil
+}
+
+// SeekTo implements the Seeker interface.
+func (hp *HTTPPuller) SeekTo(pos uint64) error {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+
+	hp.page = nil
+	hp.after = pos
+	return nil
+}
+
+// =============================================================================
+
+// HTTPStorer is a system that stores records by posting them to an
+// endpoint as a JSON array, a whole batch at a time. Any 2xx response
+// means the records are stored. Timeout limits how long a post can take.
+type HTTPStorer struct {
+	URL     string
+	Timeout time.Duration
+	Client  *http.Client // http.DefaultClient when nil.
+}
+
+// Store implements the Storer interface.
+func (hs *HTTPStorer) Store(d *Data) error {
+	return hs.StoreContext(context.Background(), d)
+}
+
+// StoreContext implements the ContextStorer interface.
+func (hs *HTTPStorer) StoreContext(ctx context.Context, d *Data) error {
+	return hs.StoreBatch(ctx, []Data{*d})
+}
+
+// StoreBatch implements the BatchStorer interface.
+func (hs *HTTPStorer) StoreBatch(ctx context.Context, data []Data) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hs.URL, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = send(ctx, hs.Client, hs.Timeout, req)
+	return err
+}
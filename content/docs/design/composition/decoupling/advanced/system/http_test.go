@@ -0,0 +1,161 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate the HTTP systems against local servers.
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// records serves n records a page at a time the way HTTPPuller expects.
+func records(n int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		after, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		page := []Data{}
+		for i := after + 1; i <= n && len(page) < limit; i++ {
+			page = append(page, Data{Seq: uint64(i), Line: "record " + strconv.Itoa(i)})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// sink collects the records posted to it.
+type sink struct {
+	mu      sync.Mutex
+	records []Data
+	posts   int
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *sink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var batch []Data
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, batch...)
+	s.posts++
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestHTTPCopy validates records are paged in and posted out in batches.
+func TestHTTPCopy(t *testing.T) {
+	src := httptest.NewServer(records(25))
+	defer src.Close()
+
+	var s sink
+	dst := httptest.NewServer(&s)
+	defer dst.Close()
+
+	t.Log("Given the need to copy records between HTTP systems.")
+	{
+		t.Log("\tWhen copying 25 records in batches of 10.")
+		{
+			p := HTTPPuller{URL: src.URL + "/records", Timeout: time.Second, PageSize: 10}
+			st := HTTPStorer{URL: dst.URL, Timeout: time.Second}
+
+			n, err := Copy(context.Background(), &p, &st, 10)
+			if n != 25 || err != io.EOF {
+				t.Fatalf("\t%s\tShould copy 25 records : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy 25 records.", succeed)
+
+			if s.posts != 3 {
+				t.Fatalf("\t%s\tShould post 3 batches : %d", failed, s.posts)
+			}
+			t.Logf("\t%s\tShould post 3 batches.", succeed)
+
+			for i, d := range s.records {
+				if d.Seq != uint64(i+1) || d.Line != "record "+strconv.Itoa(i+1) {
+					t.Fatalf("\t%s\tShould store the records in order : %+v", failed, d)
+				}
+			}
+			t.Logf("\t%s\tShould store the records in order.", succeed)
+		}
+
+		t.Log("\tWhen seeking past the first 20 records.")
+		{
+			p := HTTPPuller{URL: src.URL, PageSize: 10}
+			p.SeekTo(20)
+
+			var d Data
+			if err := p.Pull(&d); err != nil || d.Seq != 21 {
+				t.Fatalf("\t%s\tShould pull record 21 : %+v, %v", failed, d, err)
+			}
+			t.Logf("\t%s\tShould pull record 21.", succeed)
+		}
+	}
+}
+
+// TestHTTPErrors validates failures are classified.
+func TestHTTPErrors(t *testing.T) {
+	status := func(code int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}
+	}
+
+	// The server only notices the client went away once the body is read.
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}
+
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		transient bool
+	}{
+		{"unavailable", status(http.StatusServiceUnavailable), true},
+		{"throttled", status(http.StatusTooManyRequests), true},
+		{"bad request", status(http.StatusBadRequest), false},
+		{"not found", status(http.StatusNotFound), false},
+		{"too slow", slow, true},
+	}
+
+	t.Log("Given the need to tell failures worth retrying apart.")
+	{
+		for _, tt := range tests {
+			t.Logf("\tWhen the server is %s.", tt.name)
+			{
+				srv := httptest.NewServer(tt.handler)
+
+				var d Data
+				p := HTTPPuller{URL: srv.URL, Timeout: 50 * time.Millisecond}
+				perr := p.Pull(&d)
+
+				st := HTTPStorer{URL: srv.URL, Timeout: 50 * time.Millisecond}
+				serr := st.Store(&d)
+
+				srv.Close()
+
+				if perr == nil || serr == nil {
+					t.Fatalf("\t%s\tShould fail : %v, %v", failed, perr, serr)
+				}
+				if IsTransient(perr) != tt.transient || IsTransient(serr) != tt.transient {
+					t.Fatalf("\t%s\tShould be transient %v : %v, %v", failed, tt.transient, perr, serr)
+				}
+				t.Logf("\t%s\tShould be transient %v.", succeed, tt.transient)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// MemoryPuller is a system that pulls records out of memory, for tests.
+type MemoryPuller struct {
+	mu      sync.Mutex
+	records []Data
+	next    int
+}
+
+// NewMemoryPuller returns a MemoryPuller for the lines, numbered from 1.
+func NewMemoryPuller(lines ...string) *MemoryPuller {
+	records := make([]Data, len(lines))
+	for i, line := range lines {
+		records[i] = Data{Seq: uint64(i + 1), Line: line}
+	}
+
+	return &MemoryPuller{records: records}
+}
+
+// Pull implements the Puller interface.
+func (m *MemoryPuller) Pull(d *Data) error {
+	return m.PullContext(context.Background(), d)
+}
+
+// PullContext implements the ContextPuller interface.
+func (m *MemoryPuller) PullContext(ctx context.Context, d *Data) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.next == len(m.records) {
+		return io.EOF
+	}
+
+	*d = m.records[m.next]
+	m.next++
+	return nil
+}
+
+// SeekTo implements the Seeker interface.
+func (m *MemoryPuller) SeekTo(pos uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.next = min(int(pos), len(m.records))
+	return nil
+}
+
+// =============================================================================
+
+// MemoryStorer is a system that stores records in memory, for tests.
+type MemoryStorer struct {
+	mu      sync.Mutex
+	records []Data
+}
+
+// Store implements the Storer interface.
+func (m *MemoryStorer) Store(d *Data) error {
+	return m.StoreContext(context.Background(), d)
+}
+
+// StoreContext implements the ContextStorer interface.
+func (m *MemoryStorer) StoreContext(ctx context.Context, d *Data) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, *d)
+	return nil
+}
+
+// Records returns a copy of the records stored so far.
+func (m *MemoryStorer) Records() []Data {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Data(nil), m.records...)
+}
@@ -0,0 +1,208 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Observer declares behavior for watching a copy. It's told how many
+// records every batch pulled and stored and how long that took, and how
+// many records were lost to an error. The pipelined copy calls it from
+// many goroutines.
+type Observer interface {
+	Pulled(n int, d time.Duration)
+	Stored(n int, d time.Duration)
+	Failed(n int, err error)
+}
+
+// observers tells every observer about the same event.
+type observers []Observer
+
+// pulled tells the observers about a batch that was pulled.
+func (o observers) pulled(n int, d time.Duration) {
+	for _, ob := range o {
+		ob.Pulled(n, d)
+	}
+}
+
+// stored tells the observers about a batch that was stored.
+func (o observers) stored(n int, d time.Duration) {
+	for _, ob := range o {
+		ob.Stored(n, d)
+	}
+}
+
+// failed tells the observers about records lost to an error.
+func (o observers) failed(n int, err error) {
+	for _, ob := range o {
+		ob.Failed(n, err)
+	}
+}
+
+// pullFailed tells the observers about a record lost to a pull error. The
+// end of the data and the caller giving up are not failures.
+func (o observers) pullFailed(ctx context.Context, err error) {
+	if err == nil || err == io.EOF || ctx.Err() != nil {
+		return
+	}
+	o.failed(1, err)
+}
+
+// =============================================================================
+
+// Stats represents the numbers a Meter has collected. Latencies are the
+// average time a batch took.
+type Stats struct {
+	Pulled       int64         `json:"pulled"`
+	Stored       int64         `json:"stored"`
+	Failed       int64         `json:"failed"`
+	DeadLettered int64         `json:"dead_lettered"`
+	PullLatency  time.Duration `json:"pull_latency_ns"`
+	StoreLatency time.Duration `json:"store_latency_ns"`
+	Throughput   float64       `json:"throughput"` // Records stored per second.
+	Elapsed      time.Duration `json:"elapsed_ns"`
+}
+
+// String returns the numbers as a progress line.
+func (s Stats) String() string {
+	return fmt.Sprintf("pulled=%d stored=%d failed=%d dead=%d pull=%v store=%v rate=%.1f/s elapsed=%v",
+		s.Pulled, s.Stored, s.Failed, s.DeadLettered,
+		s.PullLatency.Round(time.Microsecond), s.StoreLatency.Round(time.Microsecond),
+		s.Throughput, s.Elapsed.Round(time.Millisecond))
+}
+
+// Meter is an Observer that adds up what it's told, it's safe for
+// concurrent use.
+type Meter struct {
+	start time.Time
+
+	pulled       atomic.Int64
+	stored       atomic.Int64
+	failed       atomic.Int64
+	dead         atomic.Int64
+	pullBatches  atomic.Int64
+	storeBatches atomic.Int64
+	pullTime     atomic.Int64
+	storeTime    atomic.Int64
+}
+
+// NewMeter returns a Meter that measures throughput from now.
+func NewMeter() *Meter {
+	return &Meter{start: time.Now()}
+}
+
+// Pulled implements the Observer interface.
+func (m *Meter) Pulled(n int, d time.Duration) {
+	m.pulled.Add(int64(n))
+	m.pullBatches.Add(1)
+	m.pullTime.Add(int64(d))
+}
+
+// Stored implements the Observer interface.
+func (m *Meter) Stored(n int, d time.Duration) {
+	m.stored.Add(int64(n))
+	m.storeBatches.Add(1)
+	m.storeTime.Add(int64(d))
+}
+
+// Failed implements the Observer interface.
+func (m *Meter) Failed(n int, err error) {
+	m.failed.Add(int64(n))
+}
+
+// DeadLettered implements the DeadLetterObserver interface.
+func (m *Meter) DeadLettered(n int, err error) {
+	m.dead.Add(int64(n))
+}
+
+// Stats returns the numbers collected so far.
+func (m *Meter) Stats() Stats {
+	s := Stats{
+		Pulled:       m.pulled.Load(),
+		Stored:       m.stored.Load(),
+		Failed:       m.failed.Load(),
+		DeadLettered: m.dead.Load(),
+		Elapsed:      time.Since(m.start),
+	}
+
+	if n := m.pullBatches.Load(); n > 0 {
+		s.PullLatency = time.Duration(m.pullTime.Load() / n)
+	}
+	if n := m.storeBatches.Load(); n > 0 {
+		s.StoreLatency = time.Duration(m.storeTime.Load() / n)
+	}
+	if s.Elapsed > 0 {
+		s.Throughput = float64(s.Stored) / s.Elapsed.Seconds()
+	}
+
+	return s
+}
+
+// Publish returns a Meter whose numbers are published through expvar under
+// the specified name, so they show up on /debug/vars. Like expvar itself,
+// it panics if the name is already in use.
+func Publish(name string) *Meter {
+	m := NewMeter()
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return m.Stats()
+	}))
+	return m
+}
+
+// =============================================================================
+
+// Progress is an Observer that writes a progress line at an interval.
+type Progress struct {
+	*Meter
+
+	w        io.Writer
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewProgress returns a Progress writing a line to w every interval until
+// Stop is called.
+func NewProgress(w io.Writer, every time.Duration) *Progress {
+	p := Progress{
+		Meter:    NewMeter(),
+		w:        w,
+		shutdown: make(chan struct{}),
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		t := time.NewTicker(every)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				fmt.Fprintln(p.w, p.Stats())
+			case <-p.shutdown:
+				return
+			}
+		}
+	}()
+
+	return &p
+}
+
+// Stop stops the progress lines and writes a last one with the totals.
+func (p *Progress) Stop() {
+	close(p.shutdown)
+	p.wg.Wait()
+
+	fmt.Fprintln(p.w, p.Stats())
+}
@@ -0,0 +1,91 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate observers are told about every batch.
+package system
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMeter validates the numbers add up for a copy that fails.
+func TestMeter(t *testing.T) {
+	t.Log("Given the need to watch a copy.")
+	{
+		t.Log("\tWhen a pull fails now and then and the Storer gives up.")
+		{
+			p := flaky{counter: counter{n: 20}, every: 5}
+			s := collector{failAt: 10}
+			m := NewMeter()
+
+			n, _ := Copy(context.Background(), AdaptPuller(&p), AdaptStorer(&s), 4, m)
+
+			// Pulls 5 and 10 fail, each ending a batch with no records,
+			// and the 3rd batch of 4 fails to store on its 3rd record.
+			got := m.Stats()
+			want := Stats{Pulled: 12, Stored: 10, Failed: 4}
+			if n != 10 || got.Pulled != want.Pulled || got.Stored != want.Stored || got.Failed != want.Failed {
+				t.Fatalf("\t%s\tShould count %+v : %d, %+v", failed, want, n, got)
+			}
+			t.Logf("\t%s\tShould count pulled=%d stored=%d failed=%d.", succeed, want.Pulled, want.Stored, want.Failed)
+		}
+
+		t.Log("\tWhen using the pipelined copy.")
+		{
+			p := NewMemoryPuller(strings.Split(strings.Repeat("x", 100), "")...)
+			var s MemoryStorer
+			m := NewMeter()
+
+			CopyPipelined(context.Background(), p, &s, Pipeline{Batch: 7, Pullers: 3, Storers: 3}, m)
+
+			if got := m.Stats(); got.Pulled != 100 || got.Stored != 100 {
+				t.Fatalf("\t%s\tShould count 100 records both ways : %+v", failed, got)
+			}
+			t.Logf("\t%s\tShould count 100 records both ways.", succeed)
+		}
+	}
+}
+
+// TestProgress validates the progress lines and the expvar numbers.
+func TestProgress(t *testing.T) {
+	t.Log("Given the need to report on a copy.")
+	{
+		t.Log("\tWhen printing progress.")
+		{
+			var buf bytes.Buffer
+			pr := NewProgress(&buf, time.Hour)
+
+			Copy(context.Background(), NewMemoryPuller("a", "b", "c"), &MemoryStorer{}, 2, pr)
+			pr.Stop()
+
+			if !strings.Contains(buf.String(), "pulled=3 stored=3 failed=0") {
+				t.Fatalf("\t%s\tShould print the totals when stopped : %q", failed, buf.String())
+			}
+			t.Logf("\t%s\tShould print the totals when stopped.", succeed)
+		}
+
+		t.Log("\tWhen publishing through expvar.")
+		{
+			// Names can't be published twice, and tests may run again.
+			name := fmt.Sprintf("copy_test_%d", time.Now().UnixNano())
+
+			m := Publish(name)
+			Copy(context.Background(), NewMemoryPuller("a", "b", "c"), &MemoryStorer{}, 2, m)
+
+			var s Stats
+			if err := json.Unmarshal([]byte(expvar.Get(name).String()), &s); err != nil || s.Stored != 3 {
+				t.Fatalf("\t%s\tShould publish the numbers : %+v, %v", failed, s, err)
+			}
+			t.Logf("\t%s\tShould publish the numbers.", succeed)
+		}
+	}
+}
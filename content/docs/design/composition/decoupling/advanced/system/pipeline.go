@@ -0,0 +1,182 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pipeline configures a pipelined copy. Zero values get a default of 1,
+// except Batch which defaults to 100.
+type Pipeline struct {
+	Batch   int // Number of records pulled and stored at a time.
+	Pullers int // Goroutines pulling batches.
+	Storers int // Goroutines storing batches.
+	Queue   int // Batches pulled but not yet stored before pulling waits.
+}
+
+// withDefaults returns the configuration with the zero values replaced.
+func (cfg Pipeline) withDefaults() Pipeline {
+	if cfg.Batch < 1 {
+		cfg.Batch = 100
+	}
+	if cfg.Pullers < 1 {
+		cfg.Pullers = 1
+	}
+	if cfg.Storers < 1 {
+		cfg.Storers = 1
+	}
+	if cfg.Queue < 1 {
+		cfg.Queue = 1
+	}
+	return cfg
+}
+
+// CopyPipelined knows how to pull and store data from any System with
+// pulling and storing running at the same time. Pullers fill batches and
+// hand them to storers through a bounded queue, when the storers fall
+// behind the queue fills up and the pullers wait.
+//
+// With more than one goroutine on a side, the Puller or Storer must be
+// safe for concurrent use and batches may be stored out of order.
+//
+// Like Copy, transient pull errors don't stop the copy, and it returns the
+// number of records stored with io.EOF once every pulled record is stored.
+// The first other pull or store error, or the context being done, stops
+// both sides and is the error returned. The observers are told about every
+// batch from every goroutine, so they must be safe for concurrent use.
+func CopyPipelined(ctx context.Context, p ContextPuller, s ContextStorer, cfg Pipeline, obs ...Observer) (int, error) {
+	cfg = cfg.withDefaults()
+	o := observers(obs)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Only the first error is kept, it's the reason the copy stopped.
+	var (
+		mu    sync.Mutex
+		first error
+	)
+	fail := func(err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if first == nil {
+			first = err
+			cancel()
+		}
+		return first
+	}
+
+	// The caller giving up is an error like any other. Our own cancel
+	// happens after the first error is set, so this only records the
+	// caller's.
+	done := func() {
+		fail(context.Cause(ctx))
+	}
+
+	batches := make(chan []Data, cfg.Queue)
+
+	// eof is closed once a puller reaches the end of the data, so the
+	// others stop after the batch they are working on.
+	var eofOnce sync.Once
+	eof := make(chan struct{})
+
+	var pullers sync.WaitGroup
+	pullers.Add(cfg.Pullers)
+	for i := 0; i < cfg.Pullers; i++ {
+		go func() {
+			defer pullers.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					done()
+					return
+				case <-eof:
+					return
+				default:
+				}
+
+				data := make([]Data, cfg.Batch)
+				start := time.Now()
+				i, err := pull(ctx, p, data)
+				o.pulled(i, time.Since(start))
+				o.pullFailed(ctx, err)
+				if i > 0 {
+					select {
+					case batches <- data[:i]:
+					case <-ctx.Done():
+						done()
+						return
+					}
+				}
+
+				switch {
+				case err == io.EOF:
+					eofOnce.Do(func() { close(eof) })
+					return
+
+				case err != nil && !IsTransient(err):
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Once every puller is done nothing else will be queued.
+	go func() {
+		pullers.Wait()
+		close(batches)
+	}()
+
+	var (
+		storers sync.WaitGroup
+		stored  atomic.Int64
+	)
+	storers.Add(cfg.Storers)
+	for i := 0; i < cfg.Storers; i++ {
+		go func() {
+			defer storers.Done()
+
+			for {
+				select {
+				case data, ok := <-batches:
+					if !ok {
+						return
+					}
+					start := time.Now()
+					n, err := store(ctx, s, data)
+					stored.Add(int64(n))
+					o.stored(n, time.Since(start))
+					if err != nil {
+						o.failed(len(data)-n, err)
+						fail(err)
+						return
+					}
+
+				case <-ctx.Done():
+					done()
+					return
+				}
+			}
+		}()
+	}
+	storers.Wait()
+
+	// The storers may have quit on an error with pullers still waiting
+	// to queue a batch, they leave on the cancel.
+	pullers.Wait()
+
+	// Reaching the end of the data counts as happening last, anything
+	// that went wrong before it wins.
+	return int(stored.Load()), fail(io.EOF)
+}
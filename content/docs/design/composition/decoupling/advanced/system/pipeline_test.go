@@ -0,0 +1,180 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate the pipelined copy moves every record and stops on
+// the first error.
+package system
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const succeed = "✓"
+const failed = "✗"
+
+// counter is a Puller that hands out numbered records until it has given
+// out n, then fails with err if set.
+type counter struct {
+	mu   sync.Mutex
+	next int
+	n    int
+	err  error
+}
+
+// Pull implements the Puller interface.
+func (c *counter) Pull(d *Data) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next == c.n {
+		if c.err != nil {
+			return c.err
+		}
+		return io.EOF
+	}
+
+	d.Line = strconv.Itoa(c.next)
+	c.next++
+	d.Seq = uint64(c.next)
+	return nil
+}
+
+// SeekTo implements the Seeker interface.
+func (c *counter) SeekTo(pos uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.next = int(pos)
+	return nil
+}
+
+// collector is a Storer that keeps every record and fails once it has
+// stored failAt records, if set.
+type collector struct {
+	mu     sync.Mutex
+	lines  map[string]int
+	failAt int
+	block  chan struct{}
+}
+
+// Store implements the Storer interface.
+func (c *collector) Store(d *Data) error {
+	if c.block != nil {
+		<-c.block
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.failAt > 0 && len(c.lines) == c.failAt {
+		return errors.New("pillar is full")
+	}
+	if c.lines == nil {
+		c.lines = make(map[string]int)
+	}
+	c.lines[d.Line]++
+	return nil
+}
+
+// TestCopyPipelined validates every record is stored exactly once.
+func TestCopyPipelined(t *testing.T) {
+	cfgs := []Pipeline{
+		{Batch: 3},
+		{Batch: 7, Pullers: 4, Storers: 4, Queue: 2},
+		{Batch: 1, Pullers: 8, Storers: 1, Queue: 1},
+	}
+
+	t.Log("Given the need to copy records with pulling and storing overlapping.")
+	{
+		for _, cfg := range cfgs {
+			t.Logf("\tWhen copying 1000 records with %+v.", cfg)
+			{
+				p := counter{n: 1000}
+				var s collector
+
+				n, err := CopyPipelined(context.Background(), AdaptPuller(&p), AdaptStorer(&s), cfg)
+				if n != 1000 || err != io.EOF {
+					t.Fatalf("\t%s\tShould report 1000 records with io.EOF : %d, %v", failed, n, err)
+				}
+				t.Logf("\t%s\tShould report 1000 records with io.EOF.", succeed)
+
+				if len(s.lines) != 1000 {
+					t.Fatalf("\t%s\tShould store 1000 records : %d", failed, len(s.lines))
+				}
+				for line, n := range s.lines {
+					if n != 1 {
+						t.Fatalf("\t%s\tShould store record %s once : %d", failed, line, n)
+					}
+				}
+				t.Logf("\t%s\tShould store every record once.", succeed)
+			}
+		}
+	}
+}
+
+// TestCopyPipelinedErrors validates the first error stops both sides.
+func TestCopyPipelinedErrors(t *testing.T) {
+	errPull := errors.New("Error reading data from Xenia")
+
+	t.Log("Given the need to stop a pipelined copy on the first error.")
+	{
+		t.Log("\tWhen the Puller fails.")
+		{
+			p := counter{n: 10, err: errPull}
+			var s collector
+
+			_, err := CopyPipelined(context.Background(), AdaptPuller(&p), AdaptStorer(&s), Pipeline{Batch: 3, Pullers: 2})
+			if err != errPull {
+				t.Fatalf("\t%s\tShould return the pull error : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould return the pull error.", succeed)
+		}
+
+		t.Log("\tWhen the Storer fails.")
+		{
+			p := counter{n: 1000}
+			s := collector{failAt: 5}
+
+			_, err := CopyPipelined(context.Background(), AdaptPuller(&p), AdaptStorer(&s), Pipeline{Batch: 3, Pullers: 2, Storers: 2})
+			if err == nil || err.Error() != "pillar is full" {
+				t.Fatalf("\t%s\tShould return the store error : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould return the store error.", succeed)
+		}
+
+		t.Log("\tWhen the context is cancelled with the Storer stuck.")
+		{
+			p := counter{n: 1000}
+			s := collector{block: make(chan struct{})}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				_, err := CopyPipelined(ctx, AdaptPuller(&p), AdaptStorer(&s), Pipeline{Batch: 3, Queue: 2})
+				done <- err
+			}()
+
+			// The queue fills while the store is stuck, which leaves the
+			// puller waiting instead of pulling everything.
+			cancel()
+			close(s.block)
+
+			if err := <-done; err != context.Canceled {
+				t.Fatalf("\t%s\tShould return the context error : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould return the context error.", succeed)
+
+			if p.next == p.n {
+				t.Fatalf("\t%s\tShould stop pulling once cancelled.", failed)
+			}
+			t.Logf("\t%s\tShould stop pulling once cancelled.", succeed)
+		}
+	}
+}
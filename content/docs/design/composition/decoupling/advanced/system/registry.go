@@ -0,0 +1,216 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings represents how a pipeline file configures one of its parts.
+// What Host means is up to the part, it's a host, a URL or a path.
+type Settings struct {
+	Type    string            `json:"type" yaml:"type"`
+	Host    string            `json:"host" yaml:"host"`
+	Timeout Duration          `json:"timeout" yaml:"timeout"`
+	Options map[string]string `json:"options" yaml:"options"`
+}
+
+// Factories build the parts of a pipeline from their settings.
+type (
+	PullerFactory func(s Settings) (ContextPuller, error)
+	StorerFactory func(s Settings) (ContextStorer, error)
+	StageFactory  func(s Settings) (Stage, error)
+)
+
+// registry holds the factories by the type name used in pipeline files.
+var registry = struct {
+	mu      sync.RWMutex
+	pullers map[string]PullerFactory
+	storers map[string]StorerFactory
+	stages  map[string]StageFactory
+}{
+	pullers: make(map[string]PullerFactory),
+	storers: make(map[string]StorerFactory),
+	stages:  make(map[string]StageFactory),
+}
+
+// RegisterPuller makes a Puller available to pipeline files under the
+// specified type name. Like database/sql drivers, it's meant to be called
+// from an init function and panics if the name is already taken.
+func RegisterPuller(name string, f PullerFactory) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.pullers[name]; ok {
+		panic("system: RegisterPuller called twice for " + name)
+	}
+	registry.pullers[name] = f
+}
+
+// RegisterStorer makes a Storer available to pipeline files under the
+// specified type name. It panics if the name is already taken.
+func RegisterStorer(name string, f StorerFactory) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.storers[name]; ok {
+		panic("system: RegisterStorer called twice for " + name)
+	}
+	registry.storers[name] = f
+}
+
+// RegisterStage makes a Stage available to pipeline files under the
+// specified type name. It panics if the name is already taken.
+func RegisterStage(name string, f StageFactory) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.stages[name]; ok {
+		panic("system: RegisterStage called twice for " + name)
+	}
+	registry.stages[name] = f
+}
+
+// NewPuller builds the Puller registered under the type of the settings.
+func NewPuller(s Settings) (ContextPuller, error) {
+	registry.mu.RLock()
+	f, ok := registry.pullers[s.Type]
+	registry.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown puller %q, have %s", s.Type, names(registry.pullers))
+	}
+	return f(s)
+}
+
+// NewStorer builds the Storer registered under the type of the settings.
+func NewStorer(s Settings) (ContextStorer, error) {
+	registry.mu.RLock()
+	f, ok := registry.storers[s.Type]
+	registry.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown storer %q, have %s", s.Type, names(registry.storers))
+	}
+	return f(s)
+}
+
+// NewStage builds the Stage registered under the type of the settings.
+func NewStage(s Settings) (Stage, error) {
+	registry.mu.RLock()
+	f, ok := registry.stages[s.Type]
+	registry.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown stage %q, have %s", s.Type, names(registry.stages))
+	}
+	return f(s)
+}
+
+// names returns the registered names in order, for error messages.
+func names[F any](m map[string]F) string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	s := make([]string, 0, len(m))
+	for name := range m {
+		s = append(s, name)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+// =============================================================================
+
+// The systems that come with the package.
+func init() {
+	RegisterPuller("xenia", func(s Settings) (ContextPuller, error) {
+		return AdaptPuller(&Xenia{Host: s.Host, Timeout: time.Duration(s.Timeout)}), nil
+	})
+	RegisterPuller("file", func(s Settings) (ContextPuller, error) {
+		return &FilePuller{Path: s.Host, Timeout: time.Duration(s.Timeout)}, nil
+	})
+	RegisterPuller("http", func(s Settings) (ContextPuller, error) {
+		size, err := intOption(s, "page_size", 0)
+		if err != nil {
+			return nil, err
+		}
+		return &HTTPPuller{URL: s.Host, Timeout: time.Duration(s.Timeout), PageSize: size}, nil
+	})
+
+	RegisterStorer("pillar", func(s Settings) (ContextStorer, error) {
+		return AdaptStorer(&Pillar{Host: s.Host, Timeout: time.Duration(s.Timeout)}), nil
+	})
+	RegisterStorer("file", func(s Settings) (ContextStorer, error) {
+		return &FileStorer{Path: s.Host, Timeout: time.Duration(s.Timeout)}, nil
+	})
+	RegisterStorer("http", func(s Settings) (ContextStorer, error) {
+		return &HTTPStorer{URL: s.Host, Timeout: time.Duration(s.Timeout)}, nil
+	})
+	RegisterStorer("txfile", func(s Settings) (ContextStorer, error) {
+		return &FileTxStorer{Dir: s.Host}, nil
+	})
+
+	// filter keeps the lines matching the "match" regular expression.
+	RegisterStage("filter", func(s Settings) (Stage, error) {
+		re, err := regexp.Compile(s.Options["match"])
+		if err != nil {
+			return nil, fmt.Errorf("filter: %w", err)
+		}
+		return Filter(func(d Data) bool { return re.MatchString(d.Line) }), nil
+	})
+
+	// map changes the case of the line with "case" set to upper or lower,
+	// then adds the "prefix" and "suffix" options.
+	RegisterStage("map", func(s Settings) (Stage, error) {
+		var change func(string) string
+		switch c := s.Options["case"]; c {
+		case "":
+			change = func(line string) string { return line }
+		case "upper":
+			change = strings.ToUpper
+		case "lower":
+			change = strings.ToLower
+		default:
+			return nil, fmt.Errorf("map: unknown case %q", c)
+		}
+
+		prefix, suffix := s.Options["prefix"], s.Options["suffix"]
+		return Map(func(d Data) (Data, error) {
+			d.Line = prefix + change(d.Line) + suffix
+			return d, nil
+		}), nil
+	})
+
+	// dedupe drops lines seen within the last "window" lines.
+	RegisterStage("dedupe", func(s Settings) (Stage, error) {
+		window, err := intOption(s, "window", 1000)
+		if err != nil {
+			return nil, err
+		}
+		return Dedupe(window, nil), nil
+	})
+}
+
+// intOption returns the option as an int, or the default when not set.
+func intOption(s Settings, name string, def int) (int, error) {
+	v, ok := s.Options[name]
+	if !ok {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: option %s: %w", s.Type, name, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Backoff configures how a retrying Puller waits between attempts. The
+// wait doubles after every attempt, starting at Initial and capped at Max.
+// A random part of each wait, up to half of it, is taken off so pullers
+// that failed together don't retry together.
+type Backoff struct {
+	Attempts int           // Number of attempts before giving up.
+	Initial  time.Duration // Wait after the first failure.
+	Max      time.Duration // Longest wait between attempts.
+}
+
+// delay returns how long to wait after the specified failed attempt,
+// starting at 1.
+func (b Backoff) delay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+
+	if half := int64(d / 2); half > 0 {
+		d -= time.Duration(rand.Int63n(half))
+	}
+	return d
+}
+
+// withDefaults returns the backoff with at least one attempt and Max no
+// shorter than Initial.
+func (b Backoff) withDefaults() Backoff {
+	if b.Attempts < 1 {
+		b.Attempts = 1
+	}
+	if b.Max < b.Initial {
+		b.Max = b.Initial
+	}
+	return b
+}
+
+// wait waits after the specified failed attempt, or until the context is
+// done.
+func (b Backoff) wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(b.delay(attempt))
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// =============================================================================
+
+// retryPuller retries the pulls of a Puller that fail with a transient
+// error.
+type retryPuller struct {
+	p ContextPuller
+	b Backoff
+}
+
+// Retry decorates the Puller so pulls that fail with a transient error are
+// tried again, backing off between attempts. Once the attempts run out the
+// last error is returned as a permanent one. Other errors and io.EOF are
+// returned right away.
+func Retry(p ContextPuller, b Backoff) ContextPuller {
+	return &retryPuller{p: p, b: b.withDefaults()}
+}
+
+// PullContext implements the ContextPuller interface.
+func (r *retryPuller) PullContext(ctx context.Context, d *Data) error {
+	for attempt := 1; ; attempt++ {
+		err := r.p.PullContext(ctx, d)
+		if err == nil || !IsTransient(err) {
+			return err
+		}
+
+		if attempt == r.b.Attempts {
+			return Permanent(fmt.Errorf("giving up after %d attempts: %w", attempt, err))
+		}
+
+		if err := r.b.wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// SeekTo implements the Seeker interface when the decorated Puller does.
+func (r *retryPuller) SeekTo(pos uint64) error {
+	return seekTo(r.p, pos)
+}
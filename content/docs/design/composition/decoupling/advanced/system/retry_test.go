@@ -0,0 +1,140 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate transient pull errors are retried and don't stop a
+// copy.
+package system
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// flaky is a Puller that fails with a transient error every few pulls.
+type flaky struct {
+	counter
+	every int
+	calls int
+}
+
+// Pull implements the Puller interface.
+func (f *flaky) Pull(d *Data) error {
+	f.calls++
+	if f.calls%f.every == 0 {
+		return Transient(errors.New("Error reading data from Xenia"))
+	}
+	return f.counter.Pull(d)
+}
+
+// TestRetry validates the retrying Puller only retries transient errors.
+func TestRetry(t *testing.T) {
+	b := Backoff{Attempts: 3, Initial: time.Microsecond, Max: 10 * time.Microsecond}
+
+	t.Log("Given the need to retry pulls that fail with a transient error.")
+	{
+		t.Log("\tWhen every other pull fails.")
+		{
+			p := flaky{counter: counter{n: 10}, every: 2}
+			var d Data
+
+			if err := Retry(AdaptPuller(&p), b).PullContext(context.Background(), &d); err != nil {
+				t.Fatalf("\t%s\tShould pull the record : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould pull the record.", succeed)
+		}
+
+		t.Log("\tWhen every pull fails.")
+		{
+			p := flaky{counter: counter{n: 10}, every: 1}
+			var d Data
+
+			err := Retry(AdaptPuller(&p), b).PullContext(context.Background(), &d)
+			if IsTransient(err) || p.calls != 3 {
+				t.Fatalf("\t%s\tShould give up after 3 attempts with a permanent error : %d, %v", failed, p.calls, err)
+			}
+			t.Logf("\t%s\tShould give up after 3 attempts with a permanent error.", succeed)
+		}
+
+		t.Log("\tWhen the pull fails with a permanent error.")
+		{
+			p := counter{n: 0, err: Permanent(errors.New("Xenia is gone"))}
+			var d Data
+
+			err := Retry(AdaptPuller(&p), b).PullContext(context.Background(), &d)
+			if err != p.err {
+				t.Fatalf("\t%s\tShould return the error without retrying : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould return the error without retrying.", succeed)
+		}
+
+		t.Log("\tWhen the context is cancelled while backing off.")
+		{
+			p := flaky{counter: counter{n: 10}, every: 1}
+			var d Data
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := Retry(AdaptPuller(&p), Backoff{Attempts: 3, Initial: time.Hour}).PullContext(ctx, &d)
+			if err != context.Canceled {
+				t.Fatalf("\t%s\tShould stop waiting : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould stop waiting.", succeed)
+		}
+	}
+}
+
+// TestBackoff validates the waits grow and stay within the limits.
+func TestBackoff(t *testing.T) {
+	b := Backoff{Attempts: 10, Initial: 100 * time.Millisecond, Max: time.Second}
+
+	t.Log("Given the need to back off between attempts.")
+	{
+		for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+			want *= time.Millisecond
+			t.Logf("\tWhen attempt %d has failed.", attempt+1)
+			{
+				d := b.delay(attempt + 1)
+				if d > want || d < want/2 {
+					t.Fatalf("\t%s\tShould wait between %v and %v : %v", failed, want/2, want, d)
+				}
+				t.Logf("\t%s\tShould wait between %v and %v.", succeed, want/2, want)
+			}
+		}
+	}
+}
+
+// TestCopyTransient validates Copy keeps going through transient errors.
+func TestCopyTransient(t *testing.T) {
+	t.Log("Given the need to copy from a system that fails now and then.")
+	{
+		t.Log("\tWhen every third pull fails without retries.")
+		{
+			p := flaky{counter: counter{n: 20}, every: 3}
+			var s collector
+
+			n, err := Copy(context.Background(), AdaptPuller(&p), AdaptStorer(&s), 4)
+			if n != 20 || err != io.EOF {
+				t.Fatalf("\t%s\tShould copy all 20 records : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy all 20 records.", succeed)
+		}
+
+		t.Log("\tWhen every third pull fails with the pipelined copy.")
+		{
+			p := flaky{counter: counter{n: 20}, every: 3}
+			var s collector
+
+			n, err := CopyPipelined(context.Background(), AdaptPuller(&p), AdaptStorer(&s), Pipeline{Batch: 4})
+			if n != 20 || err != io.EOF {
+				t.Fatalf("\t%s\tShould copy all 20 records : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy all 20 records.", succeed)
+		}
+	}
+}
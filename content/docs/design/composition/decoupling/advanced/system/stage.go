@@ -0,0 +1,149 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"context"
+	"sync"
+)
+
+// Stage declares behavior for changing records on their way from the
+// Puller to the Storer. Process returns false to drop the record. An
+// error is handled like a pull error, a transient one loses the record
+// and the copy keeps going, any other stops the copy.
+type Stage interface {
+	Process(ctx context.Context, d *Data) (bool, error)
+}
+
+// StageFunc is a function that acts as a Stage.
+type StageFunc func(ctx context.Context, d *Data) (bool, error)
+
+// Process implements the Stage interface.
+func (f StageFunc) Process(ctx context.Context, d *Data) (bool, error) {
+	return f(ctx, d)
+}
+
+// =============================================================================
+
+// stagedPuller runs every record it pulls through the stages.
+type stagedPuller struct {
+	p      ContextPuller
+	stages []Stage
+}
+
+// Through returns a Puller that runs every record pulled from p through
+// the stages in order, so a system reads as Puller → stage → Storer.
+// Records a stage drops are never seen by the stages after it or by the
+// Storer, and the next record is pulled in their place.
+//
+//	p := system.Through(xenia, system.Filter(valid), system.Dedupe(1000, bySeq))
+func Through(p ContextPuller, stages ...Stage) ContextPuller {
+	return &stagedPuller{p: p, stages: stages}
+}
+
+// PullContext implements the ContextPuller interface.
+func (s *stagedPuller) PullContext(ctx context.Context, d *Data) error {
+next:
+	for {
+		if err := s.p.PullContext(ctx, d); err != nil {
+			return err
+		}
+
+		for _, st := range s.stages {
+			keep, err := st.Process(ctx, d)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				continue next
+			}
+		}
+
+		return nil
+	}
+}
+
+// SeekTo implements the Seeker interface when the Puller does.
+func (s *stagedPuller) SeekTo(pos uint64) error {
+	return seekTo(s.p, pos)
+}
+
+// =============================================================================
+
+// Filter returns a Stage that only keeps the records keep returns true for.
+func Filter(keep func(d Data) bool) Stage {
+	return StageFunc(func(_ context.Context, d *Data) (bool, error) {
+		return keep(*d), nil
+	})
+}
+
+// Map returns a Stage that replaces every record with the one fn returns.
+// Use it to change or enrich records. The position of the record is kept
+// whatever fn does with it.
+func Map(fn func(d Data) (Data, error)) Stage {
+	return StageFunc(func(_ context.Context, d *Data) (bool, error) {
+		nd, err := fn(*d)
+		if err != nil {
+			return false, err
+		}
+
+		nd.Seq = d.Seq
+		*d = nd
+		return true, nil
+	})
+}
+
+// dedupe drops records with a key it has seen recently.
+type dedupe struct {
+	key func(d Data) string
+
+	mu     sync.Mutex
+	seen   map[string]struct{}
+	recent []string // Keys in the order they were seen, used as a ring.
+	next   int
+}
+
+// Dedupe returns a Stage that drops a record when one with the same key
+// was kept within the last window records. A nil key uses the line. It's
+// safe to use from the pipelined copy.
+func Dedupe(window int, key func(d Data) string) Stage {
+	if window < 1 {
+		window = 1
+	}
+	if key == nil {
+		key = func(d Data) string { return d.Line }
+	}
+
+	return &dedupe{
+		key:    key,
+		seen:   make(map[string]struct{}, window),
+		recent: make([]string, 0, window),
+	}
+}
+
+// Process implements the Stage interface.
+func (dd *dedupe) Process(_ context.Context, d *Data) (bool, error) {
+	k := dd.key(*d)
+
+	dd.mu.Lock()
+	defer dd.mu.Unlock()
+
+	if _, ok := dd.seen[k]; ok {
+		return false, nil
+	}
+
+	// Forget the oldest key once the window is full.
+	if len(dd.recent) < cap(dd.recent) {
+		dd.recent = append(dd.recent, k)
+	} else {
+		delete(dd.seen, dd.recent[dd.next])
+		dd.recent[dd.next] = k
+		dd.next = (dd.next + 1) % len(dd.recent)
+	}
+	dd.seen[k] = struct{}{}
+
+	return true, nil
+}
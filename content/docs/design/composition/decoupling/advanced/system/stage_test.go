@@ -0,0 +1,93 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate records are changed and dropped by the stages.
+package system
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestThrough validates the stages run in order between pull and store.
+func TestThrough(t *testing.T) {
+	t.Log("Given the need to change records on their way to the Storer.")
+	{
+		t.Log("\tWhen filtering, mapping and deduping.")
+		{
+			p := NewMemoryPuller("a", "#skip", "b", "a", "c", "d", "b")
+			var s MemoryStorer
+
+			sp := Through(p,
+				Filter(func(d Data) bool { return !strings.HasPrefix(d.Line, "#") }),
+				Dedupe(2, nil),
+				Map(func(d Data) (Data, error) {
+					d.Line = strings.ToUpper(d.Line)
+					return d, nil
+				}),
+			)
+
+			n, err := Copy(context.Background(), sp, &s, 2)
+			if n != 5 || err != io.EOF {
+				t.Fatalf("\t%s\tShould store 5 records : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould store 5 records.", succeed)
+
+			// The second "a" is dropped since it's within the window, the
+			// second "b" isn't since "c" and "d" pushed it out.
+			want := []Data{{1, "A"}, {3, "B"}, {5, "C"}, {6, "D"}, {7, "B"}}
+			got := s.Records()
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("\t%s\tShould store %+v : %+v", failed, want, got)
+				}
+			}
+			t.Logf("\t%s\tShould store %+v.", succeed, want)
+		}
+
+		t.Log("\tWhen a stage fails.")
+		{
+			errBad := errors.New("can't enrich record")
+			errFlaky := Transient(errors.New("lookup timed out"))
+
+			p := NewMemoryPuller("a", "flaky", "b", "bad", "c")
+			var s MemoryStorer
+
+			sp := Through(p, Map(func(d Data) (Data, error) {
+				switch d.Line {
+				case "flaky":
+					return d, errFlaky
+				case "bad":
+					return d, errBad
+				}
+				return d, nil
+			}))
+
+			n, err := Copy(context.Background(), sp, &s, 10)
+			if n != 2 || err != errBad {
+				t.Fatalf("\t%s\tShould skip the transient failure and stop on the other : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould skip the transient failure and stop on the other.", succeed)
+		}
+
+		t.Log("\tWhen resuming through the stages.")
+		{
+			p := NewMemoryPuller("a", "b", "c")
+			sp := Through(p, Filter(func(d Data) bool { return true }))
+
+			var d Data
+			if err := seekTo(sp, 2); err != nil {
+				t.Fatalf("\t%s\tShould seek the Puller : %v", failed, err)
+			}
+			if sp.PullContext(context.Background(), &d); d.Line != "c" {
+				t.Fatalf("\t%s\tShould pull the 3rd record : %+v", failed, d)
+			}
+			t.Logf("\t%s\tShould seek the Puller.", succeed)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package system takes the Puller and Storer design from the decoupling
+// examples further, copying data between any two systems.
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Data is the structure of the data we are copying. Seq is the position
+// of the record in the system it was pulled from, starting at 1, so the
+// copy can tell how far it got.
+type Data struct {
+	Seq  uint64 `json:"seq"`
+	Line string `json:"line"`
+}
+
+// =============================================================================
+
+// Puller declares behavior for pulling data.
+type Puller interface {
+	Pull(d *Data) error
+}
+
+// Storer declares behavior for storing data.
+type Storer interface {
+	Store(d *Data) error
+}
+
+// BatchStorer declares behavior for a Storer that stores a whole batch
+// with one call. When it fails, none of the batch counts as stored.
+type BatchStorer interface {
+	StoreBatch(ctx context.Context, data []Data) error
+}
+
+// TxStorer declares behavior for a Storer that stores a whole batch or
+// nothing at all. Records stored in a Tx are not visible until Commit,
+// and Abort throws them away.
+type TxStorer interface {
+	Begin(ctx context.Context) (Tx, error)
+}
+
+// Tx represents a batch being stored by a TxStorer.
+type Tx interface {
+	ContextStorer
+	Commit() error
+	Abort() error
+}
+
+// =============================================================================
+
+// Xenia is a system we need to pull data from.
+type Xenia struct {
+	Host    string
+	Timeout time.Duration
+
+	pos atomic.Uint64 // Position of the last record pulled.
+}
+
+// Pull knows how to pull data out of Xenia.
+func (x *Xenia) Pull(d *Data) error {
+	switch rand.Intn(10) {
+	case 1, 9:
+		return io.EOF
+
+	case 5:
+		return Transient(errors.New("Error reading data from Xenia"))
+
+	default:
+		d.Seq = x.pos.Add(1)
+		d.Line = "Data"
+		fmt.Println("In:", d.Seq, d.Line)
+		return nil
+	}
+}
+
+// SeekTo makes Xenia continue pulling after the specified position.
+func (x *Xenia) SeekTo(pos uint64) error {
+	x.pos.Store(pos)
+	return nil
+}
+
+// Pillar is a system we need to store data into.
+type Pillar struct {
+	Host    string
+	Timeout time.Duration
+}
+
+// Store knows how to store data into Pillar. Records are written by their
+// position, so storing one again replaces it instead of duplicating it.
+func (*Pillar) Store(d *Data) error {
+	fmt.Println("Out:", d.Seq, d.Line)
+	return nil
+}
+
+// =============================================================================
+
+// pull knows how to pull bulks of data from any Puller. The context is
+// checked before every record, so a cancel stops a batch part way.
+func pull(ctx context.Context, p ContextPuller, data []Data) (int, error) {
+	for i := range data {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := p.PullContext(ctx, &data[i]); err != nil {
+			return i, err
+		}
+	}
+
+	return len(data), nil
+}
+
+// batchDecorator is implemented by decorators that store a batch through
+// the Storer they wrap, so the Storer still gets whole batches.
+type batchDecorator interface {
+	storeBatch(ctx context.Context, data []Data) (int, error)
+}
+
+// store knows how to store bulks of data from any Storer. The context is
+// checked before every record, so a cancel stops a batch part way. A
+// TxStorer gets the batch in a transaction and a BatchStorer gets the whole
+// batch at once.
+func store(ctx context.Context, s ContextStorer, data []Data) (int, error) {
+	if bd, ok := s.(batchDecorator); ok {
+		return bd.storeBatch(ctx, data)
+	}
+
+	if ts, ok := s.(TxStorer); ok {
+		return storeTx(ctx, ts, data)
+	}
+
+	if bs, ok := s.(BatchStorer); ok {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if err := bs.StoreBatch(ctx, data); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	for i := range data {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := s.StoreContext(ctx, &data[i]); err != nil {
+			return i, err
+		}
+	}
+
+	return len(data), nil
+}
+
+// storeTx stores the batch in a transaction. Any failure, including a
+// cancel, aborts it so none of the batch is stored.
+func storeTx(ctx context.Context, ts TxStorer, data []Data) (int, error) {
+	tx, err := ts.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range data {
+		err := ctx.Err()
+		if err == nil {
+			err = tx.StoreContext(ctx, &data[i])
+		}
+		if err != nil {
+			tx.Abort()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Copy knows how to pull and store data from any System. Pulling and
+// storing take turns, a batch is stored before the next one is pulled.
+// Use AdaptPuller and AdaptStorer for systems that know nothing about
+// contexts.
+//
+// A pull that fails with a transient error is given up on and the copy
+// keeps going, use Retry to try those records again. It returns the number
+// of records stored, along with io.EOF once the Puller has no more data,
+// or the error that stopped the copy. Once the context is done, Copy stops
+// between records and returns the context's error. The observers are told
+// about every batch.
+func Copy(ctx context.Context, p ContextPuller, s ContextStorer, batch int, obs ...Observer) (int, error) {
+	return copyBatches(ctx, p, s, batch, obs, nil)
+}
+
+// copyBatches pulls and stores a batch at a time until the Puller runs
+// out of data or something goes wrong. When set, commit is called with
+// the last record stored from every batch.
+func copyBatches(ctx context.Context, p ContextPuller, s ContextStorer, batch int, obs observers, commit func(last Data) error) (int, error) {
+	data := make([]Data, batch)
+
+	var n int
+	for {
+		start := time.Now()
+		i, err := pull(ctx, p, data)
+		obs.pulled(i, time.Since(start))
+		obs.pullFailed(ctx, err)
+
+		if i > 0 {
+			start := time.Now()
+			stored, err := store(ctx, s, data[:i])
+			n += stored
+			obs.stored(stored, time.Since(start))
+
+			// Commit what made it even when the rest of the batch
+			// failed, there's no reason to store it twice.
+			if stored > 0 && commit != nil {
+				if err := commit(data[stored-1]); err != nil {
+					return n, err
+				}
+			}
+			if err != nil {
+				obs.failed(i-stored, err)
+				return n, err
+			}
+		}
+
+		if err != nil && !IsTransient(err) {
+			return n, err
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate Copy honors the context.
+package system
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// cancelAfter is a Storer that cancels the copy once it has stored n
+// records.
+type cancelAfter struct {
+	n      int
+	stored int
+	cancel context.CancelFunc
+}
+
+// Store implements the Storer interface.
+func (c *cancelAfter) Store(d *Data) error {
+	c.stored++
+	if c.stored == c.n {
+		c.cancel()
+	}
+	return nil
+}
+
+// TestCopy validates Copy stops on the context and reports how many
+// records it copied.
+func TestCopy(t *testing.T) {
+	t.Log("Given the need to stop a copy part way.")
+	{
+		t.Log("\tWhen copying every record.")
+		{
+			p := counter{n: 10}
+			var s collector
+
+			n, err := Copy(context.Background(), AdaptPuller(&p), AdaptStorer(&s), 3)
+			if n != 10 || err != io.EOF {
+				t.Fatalf("\t%s\tShould copy 10 records with io.EOF : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy 10 records with io.EOF.", succeed)
+		}
+
+		t.Log("\tWhen the context is cancelled in the middle of a batch.")
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			p := counter{n: 1000}
+			s := cancelAfter{n: 5, cancel: cancel}
+
+			n, err := Copy(ctx, AdaptPuller(&p), AdaptStorer(&s), 10)
+			if n != 5 || err != context.Canceled {
+				t.Fatalf("\t%s\tShould copy 5 records and return the context error : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy 5 records and return the context error.", succeed)
+
+			if p.next != 10 {
+				t.Fatalf("\t%s\tShould not pull another batch : %d pulled", failed, p.next)
+			}
+			t.Logf("\t%s\tShould not pull another batch.", succeed)
+		}
+
+		t.Log("\tWhen the deadline has already passed.")
+		{
+			ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+			defer cancel()
+
+			p := counter{n: 1000}
+			var s collector
+
+			n, err := Copy(ctx, AdaptPuller(&p), AdaptStorer(&s), 10)
+			if n != 0 || err != context.DeadlineExceeded || p.next != 0 {
+				t.Fatalf("\t%s\tShould copy nothing : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy nothing.", succeed)
+		}
+	}
+}
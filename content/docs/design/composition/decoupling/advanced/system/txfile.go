@@ -0,0 +1,321 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package system
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+// ErrTxDone is returned when a Tx is used after it was committed or
+// aborted.
+var ErrTxDone = errors.New("transaction already committed or aborted")
+
+// Suffixes of the files a FileTxStorer keeps in its directory.
+const (
+	batchExt = ".batch"
+	txExt    = ".tx"
+)
+
+// FileTxStorer is a system that stores every batch in its own file in a
+// directory, as a TxStorer. A batch is written to a temporary file that is
+// renamed into place on Commit, so a crash never leaves part of a batch
+// behind, only a temporary file that is removed the next time a batch
+// begins.
+//
+// A batch file is named after the positions of its first and last record.
+// Committing a batch removes the batches committed before it whose records
+// it holds all of, and Records reads every position once, so storing
+// records again doesn't duplicate them, even when the batches start and
+// end elsewhere. With Resume, a crash between the commit and saving the
+// checkpoint pulls the same records again on the next run, which makes
+// delivery exactly once. Only one FileTxStorer may write to a directory at
+// a time.
+type FileTxStorer struct {
+	Dir string
+
+	once sync.Once
+	err  error
+}
+
+// Store implements the Storer interface.
+func (fs *FileTxStorer) Store(d *Data) error {
+	return fs.StoreContext(context.Background(), d)
+}
+
+// StoreContext implements the ContextStorer interface by storing the record
+// as a batch of its own.
+func (fs *FileTxStorer) StoreContext(ctx context.Context, d *Data) error {
+	_, err := storeTx(ctx, fs, []Data{*d})
+	return err
+}
+
+// Begin implements the TxStorer interface.
+func (fs *FileTxStorer) Begin(ctx context.Context) (Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fs.once.Do(func() {
+		fs.err = fs.recover()
+	})
+	if fs.err != nil {
+		return nil, Permanent(fs.err)
+	}
+
+	f, err := os.CreateTemp(fs.Dir, "*"+txExt)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+
+	tx := fileTx{
+		dir:  fs.Dir,
+		f:    f,
+		w:    bufio.NewWriter(f),
+		seqs: make(map[uint64]bool),
+	}
+	tx.enc = json.NewEncoder(tx.w)
+
+	return &tx, nil
+}
+
+// recover creates the directory and removes what transactions that never
+// finished left behind.
+func (fs *FileTxStorer) recover() error {
+	if err := os.MkdirAll(fs.Dir, 0755); err != nil {
+		return err
+	}
+
+	left, err := filepath.Glob(filepath.Join(fs.Dir, "*"+txExt))
+	if err != nil {
+		return err
+	}
+	for _, name := range left {
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records reads back every committed record, in the order of their
+// positions. A position committed in more than one batch is read once.
+func (fs *FileTxStorer) Records() ([]Data, error) {
+	names, err := filepath.Glob(filepath.Join(fs.Dir, "*"+batchExt))
+	if err != nil {
+		return nil, err
+	}
+
+	var data []Data
+	seen := make(map[uint64]bool)
+	for _, name := range names {
+		batch, err := readBatch(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range batch {
+			if !seen[d.Seq] {
+				seen[d.Seq] = true
+				data = append(data, d)
+			}
+		}
+	}
+
+	// Batches stored by a pipelined copy interleave their positions.
+	sort.Slice(data, func(i, j int) bool { return data[i].Seq < data[j].Seq })
+
+	return data, nil
+}
+
+// readBatch reads the records of a committed batch file.
+func readBatch(name string) ([]Data, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var data []Data
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var d Data
+		if err := dec.Decode(&d); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		data = append(data, d)
+	}
+
+	return data, nil
+}
+
+// =============================================================================
+
+// fileTx is a batch being written to a temporary file, one record as JSON
+// per line.
+type fileTx struct {
+	dir   string
+	f     *os.File
+	w     *bufio.Writer
+	enc   *json.Encoder
+	seqs  map[uint64]bool // Positions of the records in the batch.
+	first uint64
+	last  uint64
+	n     int
+	done  bool
+}
+
+// StoreContext implements the ContextStorer interface.
+func (tx *fileTx) StoreContext(ctx context.Context, d *Data) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := tx.enc.Encode(d); err != nil {
+		return fileError(err)
+	}
+
+	if tx.n == 0 || d.Seq < tx.first {
+		tx.first = d.Seq
+	}
+	if d.Seq > tx.last {
+		tx.last = d.Seq
+	}
+	tx.seqs[d.Seq] = true
+	tx.n++
+
+	return nil
+}
+
+// Commit implements the Tx interface. The batch file only shows up once
+// everything in it is on disk.
+func (tx *fileTx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	if tx.n == 0 {
+		return tx.Abort()
+	}
+	tx.done = true
+
+	if err := tx.w.Flush(); err != nil {
+		tx.discard()
+		return fileError(err)
+	}
+	if err := tx.f.Sync(); err != nil {
+		tx.discard()
+		return fileError(err)
+	}
+	if err := tx.f.Close(); err != nil {
+		os.Remove(tx.f.Name())
+		return fileError(err)
+	}
+
+	name := filepath.Join(tx.dir, fmt.Sprintf("%020d-%020d%s", tx.first, tx.last, batchExt))
+	if err := os.Rename(tx.f.Name(), name); err != nil {
+		os.Remove(tx.f.Name())
+		return Permanent(err)
+	}
+
+	// The batch is in place, the ones it replaces can go.
+	if err := tx.replace(name); err != nil {
+		return Permanent(err)
+	}
+
+	// The rename is only on disk once the directory is.
+	return syncDir(tx.dir)
+}
+
+// replace removes the committed batches other than name whose records
+// are all in this one. A batch that also holds records this one doesn't,
+// like one stored by another puller of a pipelined copy, is kept.
+func (tx *fileTx) replace(name string) error {
+	names, err := filepath.Glob(filepath.Join(tx.dir, "*"+batchExt))
+	if err != nil {
+		return err
+	}
+
+	for _, old := range names {
+		var first, last uint64
+		if _, err := fmt.Sscanf(filepath.Base(old), "%d-%d", &first, &last); err != nil || old == name {
+			continue
+		}
+		if first < tx.first || last > tx.last {
+			continue
+		}
+
+		data, err := readBatch(old)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if !tx.holds(data) {
+			continue
+		}
+
+		if err := os.Remove(old); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// holds reports whether every record is in the batch.
+func (tx *fileTx) holds(data []Data) bool {
+	for _, d := range data {
+		if !tx.seqs[d.Seq] {
+			return false
+		}
+	}
+	return true
+}
+
+// Abort implements the Tx interface.
+func (tx *fileTx) Abort() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	return tx.discard()
+}
+
+// discard closes and removes the temporary file.
+func (tx *fileTx) discard() error {
+	tx.f.Close()
+	return os.Remove(tx.f.Name())
+}
+
+// syncDir flushes the directory entries to disk. Some systems can't sync
+// a directory, which is fine as long as they keep renames in order.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return Permanent(err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) {
+		return Permanent(err)
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate batches are stored all or nothing, even when the
+// process crashes part way.
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// crashEnv tells the test binary to run as the process that crashes.
+const crashEnv = "SYSTEM_TX_CRASH"
+
+// faultyTx is a TxStorer whose transactions fail, or crash the process,
+// on the record at the specified position.
+type faultyTx struct {
+	*FileTxStorer
+	at    uint64
+	crash bool
+}
+
+// Begin implements the TxStorer interface.
+func (f faultyTx) Begin(ctx context.Context) (Tx, error) {
+	tx, err := f.FileTxStorer.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return faultyRecord{Tx: tx, f: f}, nil
+}
+
+// faultyRecord is a Tx of a faultyTx.
+type faultyRecord struct {
+	Tx
+	f faultyTx
+}
+
+// StoreContext implements the ContextStorer interface.
+func (r faultyRecord) StoreContext(ctx context.Context, d *Data) error {
+	if d.Seq == r.f.at {
+		if r.f.crash {
+
+			// Leave the records stored so far in the temporary file.
+			r.Tx.(*fileTx).w.Flush()
+			os.Exit(3)
+		}
+		return errors.New("pillar is full")
+	}
+	return r.Tx.StoreContext(ctx, d)
+}
+
+// crashCheckpoint is a FileCheckpoint that crashes the process instead of
+// saving the specified position.
+type crashCheckpoint struct {
+	FileCheckpoint
+	at uint64
+}
+
+// Save implements the Checkpoint interface.
+func (c *crashCheckpoint) Save(pos uint64) error {
+	if pos == c.at {
+		os.Exit(3)
+	}
+	return c.FileCheckpoint.Save(pos)
+}
+
+// yielding is a counter that lets other goroutines run after every pull,
+// so the pullers of a pipelined copy take turns.
+type yielding struct {
+	counter
+}
+
+// Pull implements the Puller interface.
+func (y *yielding) Pull(d *Data) error {
+	defer runtime.Gosched()
+	return y.counter.Pull(d)
+}
+
+// checkRecords fails the test unless the store has the records from 1 to
+// n, once each and in order.
+func checkRecords(t *testing.T, fs *FileTxStorer, n int) {
+	t.Helper()
+
+	data, err := fs.Records()
+	if err != nil {
+		t.Fatalf("\t%s\tShould read the records back : %v", failed, err)
+	}
+	if len(data) != n {
+		t.Fatalf("\t%s\tShould have %d records : %d", failed, n, len(data))
+	}
+	for i, d := range data {
+		if d.Seq != uint64(i+1) || d.Line != strconv.Itoa(i) {
+			t.Fatalf("\t%s\tShould have record %d once and in order : %+v", failed, i+1, d)
+		}
+	}
+	t.Logf("\t%s\tShould have records 1 to %d once each.", succeed, n)
+}
+
+// leftovers returns the number of temporary files in the directory.
+func leftovers(t *testing.T, dir string) int {
+	t.Helper()
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"+txExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(names)
+}
+
+// TestTxStore validates a batch is stored all or nothing.
+func TestTxStore(t *testing.T) {
+	t.Log("Given the need to store a batch all or nothing.")
+	{
+		t.Log("\tWhen copying 10 records in batches of 4.")
+		{
+			fs := FileTxStorer{Dir: t.TempDir()}
+			p := counter{n: 10}
+
+			n, err := Copy(context.Background(), AdaptPuller(&p), &fs, 4)
+			if n != 10 || err != io.EOF {
+				t.Fatalf("\t%s\tShould copy 10 records : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy 10 records.", succeed)
+
+			checkRecords(t, &fs, 10)
+
+			names, _ := filepath.Glob(filepath.Join(fs.Dir, "*"+batchExt))
+			if len(names) != 3 {
+				t.Fatalf("\t%s\tShould commit 3 batches : %d", failed, len(names))
+			}
+			t.Logf("\t%s\tShould commit 3 batches.", succeed)
+		}
+
+		t.Log("\tWhen the 6th record fails in the 2nd batch.")
+		{
+			fs := FileTxStorer{Dir: t.TempDir()}
+			p := counter{n: 10}
+
+			n, err := Copy(context.Background(), AdaptPuller(&p), faultyTx{FileTxStorer: &fs, at: 6}, 4)
+			if n != 4 || err == nil || err == io.EOF {
+				t.Fatalf("\t%s\tShould stop after the 1st batch : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould stop after the 1st batch.", succeed)
+
+			checkRecords(t, &fs, 4)
+
+			if c := leftovers(t, fs.Dir); c != 0 {
+				t.Fatalf("\t%s\tShould abort the 2nd batch without a trace : %d", failed, c)
+			}
+			t.Logf("\t%s\tShould abort the 2nd batch without a trace.", succeed)
+		}
+
+		t.Log("\tWhen the records are stored again in bigger batches.")
+		{
+			fs := FileTxStorer{Dir: t.TempDir()}
+			for _, batch := range []int{4, 10} {
+				p := counter{n: 10}
+				if _, err := Copy(context.Background(), AdaptPuller(&p), &fs, batch); err != io.EOF {
+					t.Fatal(err)
+				}
+			}
+
+			checkRecords(t, &fs, 10)
+
+			names, _ := filepath.Glob(filepath.Join(fs.Dir, "*"+batchExt))
+			if len(names) != 1 {
+				t.Fatalf("\t%s\tShould replace the smaller batches : %d", failed, len(names))
+			}
+			t.Logf("\t%s\tShould replace the smaller batches.", succeed)
+		}
+
+		t.Log("\tWhen pullers of a pipelined copy take turns filling batches.")
+		{
+			fs := FileTxStorer{Dir: t.TempDir()}
+			p := yielding{counter: counter{n: 400}}
+
+			cfg := Pipeline{Batch: 10, Pullers: 4, Storers: 4}
+			n, err := CopyPipelined(context.Background(), AdaptPuller(&p), &fs, cfg)
+			if n != 400 || err != io.EOF {
+				t.Fatalf("\t%s\tShould copy 400 records : %d, %v", failed, n, err)
+			}
+			t.Logf("\t%s\tShould copy 400 records.", succeed)
+
+			checkRecords(t, &fs, 400)
+		}
+
+		t.Log("\tWhen a crash during a commit left a batch it was replacing.")
+		{
+			fs := FileTxStorer{Dir: t.TempDir()}
+			p := counter{n: 10}
+
+			if _, err := Copy(context.Background(), AdaptPuller(&p), &fs, 4); err != io.EOF {
+				t.Fatal(err)
+			}
+
+			// Records 3 to 6 were committed again in other batches.
+			data, err := fs.Records()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			enc := json.NewEncoder(&b)
+			for _, d := range data[2:6] {
+				enc.Encode(d)
+			}
+			name := filepath.Join(fs.Dir, fmt.Sprintf("%020d-%020d%s", 3, 6, batchExt))
+			if err := os.WriteFile(name, []byte(b.String()), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			checkRecords(t, &fs, 10)
+		}
+
+		t.Log("\tWhen using a transaction after it's committed.")
+		{
+			fs := FileTxStorer{Dir: t.TempDir()}
+			tx, err := fs.Begin(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx.StoreContext(context.Background(), &Data{Seq: 1, Line: "0"})
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("\t%s\tShould commit : %v", failed, err)
+			}
+
+			err1 := tx.StoreContext(context.Background(), &Data{Seq: 2, Line: "1"})
+			err2 := tx.Abort()
+			if err1 != ErrTxDone || err2 != ErrTxDone {
+				t.Fatalf("\t%s\tShould fail with ErrTxDone : %v, %v", failed, err1, err2)
+			}
+			t.Logf("\t%s\tShould fail with ErrTxDone.", succeed)
+
+			checkRecords(t, &fs, 1)
+		}
+	}
+}
+
+// TestTxCrash validates a copy that crashes and is resumed stores every
+// record exactly once. The test binary runs itself as the process that
+// crashes.
+func TestTxCrash(t *testing.T) {
+	if os.Getenv(crashEnv) != "" {
+		crash(os.Getenv(crashEnv))
+		return
+	}
+
+	modes := []struct {
+		mode      string
+		stored    int // Records committed when the process crashes.
+		leftovers int // Temporary files left by the crash.
+		batch     int // Size of the batches when resuming.
+		resumed   int // Records stored when resuming, batches are stored again.
+		desc      string
+	}{
+		{"store", 50, 1, 10, 50, "the process crashes half way through storing the 6th batch"},
+		{"save", 40, 0, 10, 70, "the process crashes after committing the 4th batch but before saving the checkpoint"},
+		{"save", 40, 0, 4, 70, "the process crashes before saving the checkpoint and resumes in batches of 4"},
+	}
+
+	t.Log("Given the need to store every record exactly once across crashes.")
+	{
+		for _, m := range modes {
+			t.Logf("\tWhen %s.", m.desc)
+			{
+				dir := t.TempDir()
+
+				cmd := exec.Command(os.Args[0], "-test.run=^TestTxCrash$")
+				cmd.Env = append(os.Environ(), crashEnv+"="+m.mode+":"+dir)
+				err := cmd.Run()
+
+				var ee *exec.ExitError
+				if !errors.As(err, &ee) || ee.ExitCode() != 3 {
+					t.Fatalf("\t%s\tShould crash : %v", failed, err)
+				}
+				t.Logf("\t%s\tShould crash.", succeed)
+
+				fs := FileTxStorer{Dir: filepath.Join(dir, "pillar")}
+				checkRecords(t, &fs, m.stored)
+
+				if c := leftovers(t, fs.Dir); c != m.leftovers {
+					t.Fatalf("\t%s\tShould leave %d temporary files : %d", failed, m.leftovers, c)
+				}
+				t.Logf("\t%s\tShould leave %d temporary files.", succeed, m.leftovers)
+
+				p := counter{n: 100}
+				cp := FileCheckpoint{Path: filepath.Join(dir, "pos")}
+				n, err := Resume(context.Background(), AdaptPuller(&p), &fs, m.batch, &cp)
+				if n != m.resumed || err != io.EOF {
+					t.Fatalf("\t%s\tShould store %d records resuming the copy : %d, %v", failed, m.resumed, n, err)
+				}
+				t.Logf("\t%s\tShould store %d records resuming the copy.", succeed, m.resumed)
+
+				checkRecords(t, &fs, 100)
+
+				if c := leftovers(t, fs.Dir); c != 0 {
+					t.Fatalf("\t%s\tShould clean up the temporary files : %d", failed, c)
+				}
+				t.Logf("\t%s\tShould clean up the temporary files.", succeed)
+			}
+		}
+	}
+}
+
+// crash runs a copy of 100 records in batches of 10 that crashes the
+// process as the mode says.
+func crash(env string) {
+	mode, dir, _ := strings.Cut(env, ":")
+
+	fs := FileTxStorer{Dir: filepath.Join(dir, "pillar")}
+	cp := crashCheckpoint{FileCheckpoint: FileCheckpoint{Path: filepath.Join(dir, "pos")}}
+	var s ContextStorer = &fs
+
+	switch mode {
+	case "store":
+		s = faultyTx{FileTxStorer: &fs, at: 55, crash: true}
+	case "save":
+		cp.at = 40
+	}
+
+	p := counter{n: 100}
+	Resume(context.Background(), AdaptPuller(&p), s, 10, &cp)
+
+	// Getting here means the copy didn't crash.
+	os.Exit(1)
+}
@@ -0,0 +1,351 @@
+// Copyright 2014 Ardan Studios
+//
+// This program provides a load generator for the search service. It drives
+// the /search endpoint with a mix of terms at a given concurrency and rate
+// and reports the latency percentiles, error rate and throughput. Profiles
+// can be captured from the service while the load is running.
+//
+// Run it from the project directory so the service templates can be found
+// when the service is started in-process.
+//
+// go run ./loadgen -c 50 -n 5000 -terms "trump:4,economy:2,brexit:1"
+// go run ./loadgen -url http://localhost:5000 -rate 200 -d 30s -profile ./profiles
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	_ "net/http/pprof"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/cedrickchee/ultimate-go/profiling/project/service"
+)
+
+var (
+	target      = flag.String("url", "", "base url of a running service, empty starts one in-process")
+	terms       = flag.String("terms", "trump:4,economy:2,election:1", "comma separated term:weight mix to search for")
+	sources     = flag.String("sources", "cnn,nyt,bbc", "comma separated feeds to search")
+	concurrency = flag.Int("c", 10, "number of concurrent workers")
+	rate        = flag.Float64("rate", 0, "requests per second across all workers, 0 for no limit")
+	requests    = flag.Int("n", 1000, "number of requests to send, 0 for no limit, defaults to 0 with -d")
+	duration    = flag.Duration("d", 0, "how long to send requests for, 0 for no limit")
+	profile     = flag.String("profile", "", "directory to write the cpu, heap and trace profiles to")
+	profileFor  = flag.Duration("pd", 5*time.Second, "how long to capture the cpu profile and trace for")
+)
+
+// term represents a search term and how often it should be used.
+type term struct {
+	value  string
+	weight int
+}
+
+// mix knows how to pick terms based on their weight.
+type mix struct {
+	terms []term
+	total int
+}
+
+// parseMix parses a term:weight list. A term without a weight gets 1.
+func parseMix(s string) (mix, error) {
+	var m mix
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		t := term{value: f, weight: 1}
+		if i := strings.LastIndex(f, ":"); i != -1 {
+			w, err := strconv.Atoi(f[i+1:])
+			if err != nil || w < 1 {
+				return mix{}, fmt.Errorf("invalid weight in %q", f)
+			}
+			t = term{value: f[:i], weight: w}
+		}
+
+		m.terms = append(m.terms, t)
+		m.total += t.weight
+	}
+
+	if len(m.terms) == 0 {
+		return mix{}, fmt.Errorf("no terms provided")
+	}
+
+	return m, nil
+}
+
+// pick returns a term based on the weights in the mix.
+func (m mix) pick(r *rand.Rand) string {
+	n := r.Intn(m.total)
+	for _, t := range m.terms {
+		if n < t.weight {
+			return t.value
+		}
+		n -= t.weight
+	}
+	return m.terms[len(m.terms)-1].value
+}
+
+// result represents the outcome of a single request.
+type result struct {
+	latency time.Duration
+	err     error
+}
+
+func main() {
+	flag.Parse()
+
+	// A duration on its own means run for that long, not until the
+	// default number of requests is sent.
+	if *duration > 0 && !isSet("n") {
+		*requests = 0
+	}
+
+	m, err := parseMix(*terms)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *requests == 0 && *duration == 0 {
+		log.Fatalln("one of -n or -d must be provided")
+	}
+
+	// Start the service in-process when no url is provided. The service
+	// package binds its routes to the default mux.
+	base := *target
+	if base == "" {
+		srv := httptest.NewServer(http.DefaultServeMux)
+		defer srv.Close()
+		base = srv.URL
+		log.Println("Started in-process service on:", base)
+	}
+
+	client := http.Client{
+		Timeout: 35 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: *concurrency,
+		},
+	}
+
+	// Capture the profiles while the load is being applied.
+	var pwg sync.WaitGroup
+	if *profile != "" {
+		if err := os.MkdirAll(*profile, 0755); err != nil {
+			log.Fatalln(err)
+		}
+
+		secs := strconv.Itoa(int(profileFor.Seconds()))
+		pwg.Add(2)
+		go func() {
+			defer pwg.Done()
+			capture(&client, base+"/debug/pprof/profile?seconds="+secs, filepath.Join(*profile, "cpu.pprof"))
+		}()
+		go func() {
+			defer pwg.Done()
+			capture(&client, base+"/debug/pprof/trace?seconds="+secs, filepath.Join(*profile, "trace.out"))
+		}()
+	}
+
+	jobs := schedule(m)
+	results := make(chan []result, *concurrency)
+
+	start := time.Now()
+
+	for i := 0; i < *concurrency; i++ {
+		go func() {
+			var rs []result
+			for t := range jobs {
+				rs = append(rs, search(&client, base, t))
+			}
+			results <- rs
+		}()
+	}
+
+	var all []result
+	for i := 0; i < *concurrency; i++ {
+		all = append(all, <-results...)
+	}
+
+	elapsed := time.Since(start)
+
+	// Take the heap once the load is done and wait for the cpu profile
+	// and trace to finish.
+	if *profile != "" {
+		capture(&client, base+"/debug/pprof/heap", filepath.Join(*profile, "heap.pprof"))
+		pwg.Wait()
+	}
+
+	report(os.Stdout, all, elapsed)
+}
+
+// schedule returns a channel of terms to search for. The channel is
+// closed once the number of requests or the duration has been reached.
+// When a rate is provided the terms are paced to match it.
+func schedule(m mix) <-chan string {
+	jobs := make(chan string)
+
+	go func() {
+		defer close(jobs)
+
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+		var tick <-chan time.Time
+		if *rate > 0 {
+			t := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+			defer t.Stop()
+			tick = t.C
+		}
+
+		var done <-chan time.Time
+		if *duration > 0 {
+			done = time.After(*duration)
+		}
+
+		for n := 0; *requests == 0 || n < *requests; n++ {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-done:
+					return
+				}
+			}
+
+			select {
+			case jobs <- m.pick(r):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return jobs
+}
+
+// search performs a single search against the service.
+func search(client *http.Client, base string, t string) result {
+	form := url.Values{"term": {t}}
+	for _, s := range strings.Split(*sources, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			form.Set(s, "on")
+		}
+	}
+
+	start := time.Now()
+
+	resp, err := client.PostForm(base+"/search", form)
+	if err != nil {
+		return result{latency: time.Since(start), err: err}
+	}
+	defer resp.Body.Close()
+
+	// Read the full response so the latency includes the body.
+	_, err = io.Copy(io.Discard, resp.Body)
+	lat := time.Since(start)
+
+	switch {
+	case err != nil:
+		return result{latency: lat, err: err}
+	case resp.StatusCode != http.StatusOK:
+		return result{latency: lat, err: fmt.Errorf("status code %d", resp.StatusCode)}
+	}
+
+	return result{latency: lat}
+}
+
+// capture saves the response from a profiling endpoint to the file.
+func capture(client *http.Client, uri string, file string) {
+	resp, err := client.Get(uri)
+	if err != nil {
+		log.Println("ERROR: capturing", uri, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Println("ERROR: capturing", uri, "status code", resp.StatusCode)
+		return
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		log.Println("ERROR: capturing", uri, err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		log.Println("ERROR: capturing", uri, err)
+		return
+	}
+
+	log.Println("Captured", file)
+}
+
+// report writes the latency percentiles, error rate and throughput.
+func report(w io.Writer, rs []result, elapsed time.Duration) {
+	if len(rs) == 0 {
+		fmt.Fprintln(w, "No requests sent")
+		return
+	}
+
+	lats := make([]time.Duration, 0, len(rs))
+	errs := make(map[string]int)
+	var failed int
+	for _, r := range rs {
+		lats = append(lats, r.latency)
+		if r.err != nil {
+			failed++
+			errs[r.err.Error()]++
+		}
+	}
+
+	sort.Slice(lats, func(i, j int) bool { return lats[i] < lats[j] })
+
+	fmt.Fprintf(w, "Requests:   %d in %v\n", len(rs), elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Throughput: %.2f req/s\n", float64(len(rs))/elapsed.Seconds())
+	fmt.Fprintf(w, "Errors:     %d (%.2f%%)\n", failed, 100*float64(failed)/float64(len(rs)))
+	for e, n := range errs {
+		fmt.Fprintf(w, "  %6d  %s\n", n, e)
+	}
+
+	fmt.Fprintln(w, "Latency:")
+	for _, p := range []float64{50, 90, 95, 99} {
+		fmt.Fprintf(w, "  p%-4v %v\n", p, percentile(lats, p))
+	}
+	fmt.Fprintf(w, "  max   %v\n", lats[len(lats)-1])
+}
+
+// percentile returns the pth percentile of the sorted latencies.
+func percentile(lats []time.Duration, p float64) time.Duration {
+	i := int(float64(len(lats))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(lats) {
+		i = len(lats) - 1
+	}
+	return lats[i]
+}
+
+// isSet reports whether the flag was given on the command line.
+func isSet(name string) bool {
+	var set bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -21,18 +21,19 @@ func NewBBC() Searcher {
 }
 
 // Search performs a search against the CNN RSS feeds.
-func (BBC) Search(uid string, term string, found chan<- []Result) {
-	results := []Result{}
+func (BBC) Search(uid string, m *Matcher, found chan<- Response) {
+	resp := Response{Results: []Result{}}
 
 	for _, feed := range bbcFeeds {
-		res, err := rssSearch(uid, term, "BBC", feed)
+		res, err := rssSearch(uid, m, "BBC", feed)
 		if err != nil {
 			log.Println("ERROR: ", err)
+			resp.Errors = append(resp.Errors, feedError("BBC", feed, err))
 			continue
 		}
 
-		results = append(results, res...)
+		resp.Results = append(resp.Results, res...)
 	}
 
-	found <- results
+	found <- resp
 }
@@ -21,18 +21,19 @@ func NewCNN() Searcher {
 }
 
 // Search performs a search against the CNN RSS feeds.
-func (CNN) Search(uid string, term string, found chan<- []Result) {
-	results := []Result{}
+func (CNN) Search(uid string, m *Matcher, found chan<- Response) {
+	resp := Response{Results: []Result{}}
 
 	for _, feed := range cnnFeeds {
-		res, err := rssSearch(uid, term, "CNN", feed)
+		res, err := rssSearch(uid, m, "CNN", feed)
 		if err != nil {
 			log.Println("ERROR: ", err)
+			resp.Errors = append(resp.Errors, feedError("CNN", feed, err))
 			continue
 		}
 
-		results = append(results, res...)
+		resp.Results = append(resp.Results, res...)
 	}
 
-	found <- results
+	found <- resp
 }
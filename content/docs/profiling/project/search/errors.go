@@ -0,0 +1,80 @@
+// Copyright 2014 Ardan Studios
+//
+
+package search
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrorKind identifies the category of failure a feed experienced.
+type ErrorKind int
+
+// Set of error categories a feed can fail with.
+const (
+	KindNetwork ErrorKind = iota + 1
+	KindStatus
+	KindDecode
+	KindTimeout
+)
+
+// String returns the name of the error category.
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNetwork:
+		return "network"
+	case KindStatus:
+		return "http status"
+	case KindDecode:
+		return "decode"
+	case KindTimeout:
+		return "timeout"
+	}
+	return "unknown"
+}
+
+// FeedError is returned when a feed for an engine could not be searched.
+type FeedError struct {
+	Engine     string
+	URI        string
+	Kind       ErrorKind
+	StatusCode int
+	Err        error
+}
+
+// Error implements the error interface.
+func (e *FeedError) Error() string {
+	if e.Kind == KindStatus {
+		return fmt.Sprintf("%s: %s: %s error: %d", e.Engine, e.URI, e.Kind, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s: %s error: %v", e.Engine, e.URI, e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *FeedError) Unwrap() error {
+	return e.Err
+}
+
+// classify returns a FeedError of the specified kind unless the error
+// was caused by a timeout.
+func classify(engine, uri string, kind ErrorKind, err error) *FeedError {
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		kind = KindTimeout
+	}
+
+	return &FeedError{Engine: engine, URI: uri, Kind: kind, Err: err}
+}
+
+// feedError returns the error as a FeedError, treating any error that
+// is not already one as a network failure.
+func feedError(engine, uri string, err error) *FeedError {
+	var ferr *FeedError
+	if errors.As(err, &ferr) {
+		return ferr
+	}
+
+	return classify(engine, uri, KindNetwork, err)
+}
@@ -0,0 +1,88 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate feeds that can't be searched are reported by the kind
+// of failure.
+package search
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestFeedErrors validates a search reports every feed that failed along
+// with why it failed.
+func TestFeedErrors(t *testing.T) {
+	status := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer status.Close()
+
+	decode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<rss><channel><item>"))
+	}))
+	defer decode.Close()
+
+	// Answer once the client has given up on the request.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.Write([]byte(feed))
+	}))
+	defer slow.Close()
+
+	// Nothing is listening once the server is closed.
+	network := httptest.NewServer(http.NotFoundHandler())
+	network.Close()
+
+	tests := []struct {
+		uri  string
+		kind ErrorKind
+		code int
+	}{
+		{status.URL, KindStatus, http.StatusInternalServerError},
+		{decode.URL, KindDecode, 0},
+		{slow.URL, KindTimeout, 0},
+		{network.URL, KindNetwork, 0},
+	}
+
+	// Point the CNN searcher at the test servers and give up on a feed
+	// quickly.
+	feeds, timeout := cnnFeeds, client.Timeout
+	defer func() {
+		cnnFeeds, client.Timeout = feeds, timeout
+	}()
+
+	cnnFeeds = nil
+	for _, tt := range tests {
+		cnnFeeds = append(cnnFeeds, tt.uri)
+	}
+	client.Timeout = 100 * time.Millisecond
+
+	t.Log("Given the need to report the feeds that can't be searched.")
+	{
+		t.Log("\tWhen the feeds fail with a status, bad XML, a timeout and no server.")
+		{
+			resp := Submit("1", Options{Term: "first", CNN: true})
+
+			if !resp.Failed() || len(resp.Errors) != len(tests) || len(resp.Results) != 0 {
+				t.Fatalf("\t%s\tShould report %d failed feeds : %d, %d", failed, len(tests), len(resp.Errors), len(resp.Results))
+			}
+			t.Logf("\t%s\tShould report %d failed feeds.", succeed, len(tests))
+
+			for i, tt := range tests {
+				ferr := resp.Errors[i]
+				if ferr.Engine != "CNN" || ferr.URI != tt.uri || ferr.Kind != tt.kind || ferr.StatusCode != tt.code {
+					t.Fatalf("\t%s\tShould fail with a %s error : %v", failed, tt.kind, ferr)
+				}
+				t.Logf("\t%s\tShould fail with a %s error : %v", succeed, tt.kind, ferr)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 Ardan Studios
+//
+
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Matcher knows how to find a search term inside of feed text regardless
+// of case, accents or the Unicode form the text was written in.
+type Matcher struct {
+	term      string
+	wholeWord bool
+}
+
+// NewMatcher returns a Matcher for the specified term. When wholeWord is
+// true the term only matches when it is not part of a larger word.
+func NewMatcher(term string, wholeWord bool) *Matcher {
+	return &Matcher{
+		term:      normalize(term),
+		wholeWord: wholeWord,
+	}
+}
+
+// Match reports whether the text contains the search term.
+func (m *Matcher) Match(text string) bool {
+	if m.term == "" {
+		return false
+	}
+
+	text = normalize(text)
+
+	if !m.wholeWord {
+		return strings.Contains(text, m.term)
+	}
+
+	// Look at every occurrence of the term until we find one that sits
+	// on word boundaries.
+	for start := 0; start < len(text); {
+		i := strings.Index(text[start:], m.term)
+		if i == -1 {
+			return false
+		}
+		i += start
+		end := i + len(m.term)
+
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+
+		_, size := utf8.DecodeRuneInString(text[i:])
+		start = i + size
+	}
+
+	return false
+}
+
+// isWordRune reports whether the rune is part of a word. The RuneError
+// returned at either end of the text is treated as a boundary.
+func isWordRune(r rune) bool {
+	if r == utf8.RuneError {
+		return false
+	}
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// normalize case folds the text, strips the diacritics and returns the
+// result in composed form. The transformers are not safe for concurrent
+// use so a new chain is built for every call.
+func normalize(s string) string {
+	t := transform.Chain(
+		cases.Fold(),
+		norm.NFKD,
+		runes.Remove(runes.In(unicode.Mn)),
+		norm.NFC,
+	)
+
+	r, _, err := transform.String(t, s)
+	if err != nil {
+		return strings.ToLower(s)
+	}
+
+	return r
+}
@@ -0,0 +1,49 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package search
+
+import "testing"
+
+const succeed = "✓"
+const failed = "✗"
+
+// TestMatch validates terms match regardless of case, accents and
+// Unicode form, and that whole word matching respects word boundaries.
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		term      string
+		text      string
+		wholeWord bool
+		match     bool
+	}{
+		{"zurich", "Flooding in Zürich", false, true},
+		{"Zürich", "FLOODING IN ZURICH", false, true},
+		{"Zürich", "Flooding in Zürich", false, true},
+		{"istanbul", "İSTANBUL today", false, true},
+		{"ΟΔΟΣ", "μια οδος", false, true},
+		{"ﬁle", "the file was lost", false, true},
+		{"art", "a party downtown", false, true},
+		{"art", "a party downtown", true, false},
+		{"art", "modern art, party art", true, true},
+		{"art", "party art_gallery", true, false},
+		{"", "anything", false, false},
+	}
+
+	t.Log("Given the need to match terms inside of feed text.")
+	{
+		for i, tt := range tests {
+			t.Logf("\tTest: %d\tWhen matching %q in %q with whole word %v", i, tt.term, tt.text, tt.wholeWord)
+			{
+				m := NewMatcher(tt.term, tt.wholeWord)
+				if got := m.Match(tt.text); got != tt.match {
+					t.Errorf("\t%s\tShould get a match of %v : %v", failed, tt.match, got)
+					continue
+				}
+				t.Logf("\t%s\tShould get a match of %v.", succeed, tt.match)
+			}
+		}
+	}
+}
@@ -21,18 +21,19 @@ func NewNYT() Searcher {
 }
 
 // Search performs a search against the NYT RSS feeds.
-func (NYT) Search(uid string, term string, found chan<- []Result) {
-	results := []Result{}
+func (NYT) Search(uid string, m *Matcher, found chan<- Response) {
+	resp := Response{Results: []Result{}}
 
 	for _, feed := range nytFeeds {
-		res, err := rssSearch(uid, term, "NYT", feed)
+		res, err := rssSearch(uid, m, "NYT", feed)
 		if err != nil {
 			log.Println("ERROR: ", err)
+			resp.Errors = append(resp.Errors, feedError("NYT", feed, err))
 			continue
 		}
 
-		results = append(results, res...)
+		resp.Results = append(resp.Results, res...)
 	}
 
-	found <- results
+	found <- resp
 }
@@ -7,7 +7,6 @@ import (
 	"encoding/xml"
 	"log"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
@@ -25,6 +24,12 @@ const (
 
 var cache = gc.New(expiration, cleanup)
 
+// client is used to pull down the feeds. The timeout keeps a slow feed
+// from holding up the search past the server's write timeout.
+var client = http.Client{
+	Timeout: 10 * time.Second,
+}
+
 var fetch = struct {
 	sync.Mutex
 	m map[string]*sync.Mutex
@@ -66,7 +71,7 @@ type (
 )
 
 // rssSearch is used against any RSS feeds.
-func rssSearch(uid, term, engine, uri string) ([]Result, error) {
+func rssSearch(uid string, m *Matcher, engine, uri string) ([]Result, error) {
 	var mu *sync.Mutex
 	fetch.Lock()
 	{
@@ -93,16 +98,9 @@ func rssSearch(uid, term, engine, uri string) ([]Result, error) {
 		default:
 
 			// Pull down the rss feed.
-			resp, err := http.Get(uri)
-			if err != nil {
-				return []Result{}, err
-			}
-
-			// Schedule the close of the response body.
-			defer resp.Body.Close()
-
-			// Decode the results into a document.
-			if err := xml.NewDecoder(resp.Body).Decode(&d); err != nil {
+			var err error
+			if d, err = fetchDocument(engine, uri); err != nil {
+				mu.Unlock()
 				return []Result{}, err
 			}
 
@@ -119,7 +117,7 @@ func rssSearch(uid, term, engine, uri string) ([]Result, error) {
 
 	// Capture the data we need for our results if we find the search term.
 	for _, item := range d.Channel.Items {
-		if strings.Contains(strings.ToLower(item.Description), strings.ToLower(term)) {
+		if m.Match(item.Description) {
 			results = append(results, Result{
 				Engine:  engine,
 				Title:   item.Title,
@@ -131,3 +129,38 @@ func rssSearch(uid, term, engine, uri string) ([]Result, error) {
 
 	return results, nil
 }
+
+// fetchDocument pulls down and decodes the rss feed, recording the
+// outcome for the feed status page.
+func fetchDocument(engine, uri string) (Document, error) {
+	start := time.Now()
+
+	// Pull down the rss feed.
+	resp, err := client.Get(uri)
+	if err != nil {
+		ferr := classify(engine, uri, KindNetwork, err)
+		recordFetch(engine, uri, start, 0, 0, ferr)
+		return Document{}, ferr
+	}
+
+	// Schedule the close of the response body.
+	defer resp.Body.Close()
+
+	// Only a successful response has a document worth decoding.
+	if resp.StatusCode != http.StatusOK {
+		ferr := &FeedError{Engine: engine, URI: uri, Kind: KindStatus, StatusCode: resp.StatusCode}
+		recordFetch(engine, uri, start, resp.StatusCode, 0, ferr)
+		return Document{}, ferr
+	}
+
+	// Decode the results into a document.
+	var d Document
+	if err := xml.NewDecoder(resp.Body).Decode(&d); err != nil {
+		ferr := classify(engine, uri, KindDecode, err)
+		recordFetch(engine, uri, start, resp.StatusCode, 0, ferr)
+		return Document{}, ferr
+	}
+
+	recordFetch(engine, uri, start, resp.StatusCode, len(d.Channel.Items), nil)
+	return d, nil
+}
@@ -14,9 +14,10 @@ var final []Result
 func BenchmarkRssSearch(b *testing.B) {
 	var result []Result
 	var err error
+	m := NewMatcher("trump", false)
 
 	for i := 0; i < b.N; i++ {
-		result, err = rssSearch("1", "trump", "nyt", "http://rss.nytimes.com/services/xml/rss/nyt/HomePage.xml")
+		result, err = rssSearch("1", m, "nyt", "http://rss.nytimes.com/services/xml/rss/nyt/HomePage.xml")
 		if err != nil {
 			b.FailNow()
 		}
@@ -9,11 +9,12 @@ import "html/template"
 
 // Options provides the search options for performing searches.
 type Options struct {
-	Term  string
-	CNN   bool
-	NYT   bool
-	BBC   bool
-	First bool
+	Term      string
+	CNN       bool
+	NYT       bool
+	BBC       bool
+	First     bool
+	WholeWord bool
 }
 
 // Result represents a search result that was found.
@@ -34,15 +35,27 @@ func (r *Result) ContentHTML() template.HTML {
 	return template.HTML(r.Content)
 }
 
+// Response represents the results found by the searches along with the
+// errors from any engine feeds that could not be searched.
+type Response struct {
+	Results []Result
+	Errors  []*FeedError
+}
+
+// Failed reports whether any of the feeds could not be searched.
+func (r *Response) Failed() bool {
+	return len(r.Errors) > 0
+}
+
 // Searcher declares an interface used to leverage different
 // search engines to find results.
 type Searcher interface {
-	Search(uid string, term string, found chan<- []Result)
+	Search(uid string, m *Matcher, found chan<- Response)
 }
 
 // Submit uses goroutines and channels to perform a search against the
 // feeds concurrently.
-func Submit(uid string, options Options) []Result {
+func Submit(uid string, options Options) Response {
 	searchers := make(map[string]Searcher)
 
 	// Create a CNN Searcher if checked.
@@ -60,15 +73,18 @@ func Submit(uid string, options Options) []Result {
 		searchers["bbc"] = NewBBC()
 	}
 
-	results := make(chan []Result)
+	results := make(chan Response)
+
+	// All the searchers share the same normalized term.
+	m := NewMatcher(options.Term, options.WholeWord)
 
 	// Perform the searches concurrently. Using a map because
 	// it returns the searchers in a random order every time.
 	for _, searcher := range searchers {
-		go searcher.Search(uid, options.Term, results)
+		go searcher.Search(uid, m, results)
 	}
 
-	var final []Result
+	var final Response
 
 	// Wait for the results to come back.
 	for search := 0; search < len(searchers); search++ {
@@ -76,7 +92,7 @@ func Submit(uid string, options Options) []Result {
 		// If we just want the first result, don't wait any longer by
 		// concurrently discarding the remaining results.
 		// Failing to do so will leave the Searchers blocked forever.
-		if options.First && (search > 0 && len(final) > 0) {
+		if options.First && (search > 0 && len(final.Results) > 0) {
 			go func() {
 				<-results
 			}()
@@ -86,8 +102,9 @@ func Submit(uid string, options Options) []Result {
 		// Wait to recieve results.
 		found := <-results
 
-		// Save the results to the final slice.
-		final = append(final, found...)
+		// Save the results and errors to the final response.
+		final.Results = append(final.Results, found.Results...)
+		final.Errors = append(final.Errors, found.Errors...)
 	}
 
 	return final
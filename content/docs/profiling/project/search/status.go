@@ -0,0 +1,111 @@
+// Copyright 2014 Ardan Studios
+//
+
+package search
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// FeedStatus represents the health of a single RSS feed as observed
+// by the searches performed against it.
+type FeedStatus struct {
+	Engine       string        `json:"engine"`
+	URI          string        `json:"uri"`
+	LastFetch    time.Time     `json:"last_fetch"`
+	StatusCode   int           `json:"status_code"`
+	Error        string        `json:"error,omitempty"`
+	Items        int           `json:"items"`
+	CacheAge     time.Duration `json:"cache_age_ns"`
+	AvgLatency   time.Duration `json:"avg_latency_ns"`
+	Fetches      int           `json:"fetches"`
+	cachedAt     time.Time
+	totalLatency time.Duration
+}
+
+// status maintains the health of every known feed keyed by uri.
+var status = struct {
+	sync.RWMutex
+	m map[string]*FeedStatus
+}{
+	m: make(map[string]*FeedStatus),
+}
+
+// init registers every feed so they show up before the first search.
+func init() {
+	register("CNN", cnnFeeds)
+	register("NYT", nytFeeds)
+	register("BBC", bbcFeeds)
+}
+
+// register adds the specified feeds for the engine to the status set.
+func register(engine string, feeds []string) {
+	status.Lock()
+	{
+		for _, uri := range feeds {
+			status.m[uri] = &FeedStatus{Engine: engine, URI: uri}
+		}
+	}
+	status.Unlock()
+}
+
+// recordFetch captures the outcome of pulling down a feed.
+func recordFetch(engine, uri string, start time.Time, code int, items int, err error) {
+	now := time.Now()
+
+	status.Lock()
+	{
+		fs, found := status.m[uri]
+		if !found {
+			fs = &FeedStatus{Engine: engine, URI: uri}
+			status.m[uri] = fs
+		}
+
+		fs.LastFetch = start
+		fs.StatusCode = code
+		fs.Fetches++
+		fs.totalLatency += now.Sub(start)
+
+		switch {
+		case err != nil:
+			fs.Error = err.Error()
+
+		default:
+			fs.Error = ""
+			fs.Items = items
+			fs.cachedAt = now
+		}
+	}
+	status.Unlock()
+}
+
+// Feeds returns a snapshot of the health of every known feed.
+func Feeds() []FeedStatus {
+	now := time.Now()
+
+	status.RLock()
+	feeds := make([]FeedStatus, 0, len(status.m))
+	for _, fs := range status.m {
+		f := *fs
+		if !f.cachedAt.IsZero() {
+			f.CacheAge = now.Sub(f.cachedAt)
+		}
+		if f.Fetches > 0 {
+			f.AvgLatency = f.totalLatency / time.Duration(f.Fetches)
+		}
+		feeds = append(feeds, f)
+	}
+	status.RUnlock()
+
+	// Keep the order stable for the status page.
+	sort.Slice(feeds, func(i, j int) bool {
+		if feeds[i].Engine != feeds[j].Engine {
+			return feeds[i].Engine < feeds[j].Engine
+		}
+		return feeds[i].URI < feeds[j].URI
+	})
+
+	return feeds
+}
@@ -0,0 +1,99 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Tests to validate the health of a feed is recorded as it's fetched.
+package search
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// feed is an RSS document with two items.
+const feed = `<rss><channel>
+<item><title>one</title><description>first</description></item>
+<item><title>two</title><description>second</description></item>
+</channel></rss>`
+
+// feedStatus returns the status of the feed with the uri.
+func feedStatus(t *testing.T, uri string) FeedStatus {
+	t.Helper()
+
+	for _, fs := range Feeds() {
+		if fs.URI == uri {
+			return fs
+		}
+	}
+
+	t.Fatalf("\t%s\tShould find the status of %s.", failed, uri)
+	return FeedStatus{}
+}
+
+// TestStatus validates the fetches, latency, errors and cache age of a feed
+// are recorded.
+func TestStatus(t *testing.T) {
+	const latency = 20 * time.Millisecond
+
+	// The first fetch fails, the ones after it succeed.
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(feed))
+	}))
+	defer srv.Close()
+
+	t.Log("Given the need to report the health of a feed.")
+	{
+		t.Log("\tWhen the feed fails to fetch.")
+		{
+			if _, err := fetchDocument("TEST", srv.URL); err == nil {
+				t.Fatalf("\t%s\tShould fail to fetch the feed.", failed)
+			}
+
+			fs := feedStatus(t, srv.URL)
+			if fs.Engine != "TEST" || fs.Fetches != 1 || fs.StatusCode != http.StatusInternalServerError || fs.Error == "" {
+				t.Fatalf("\t%s\tShould record the failed fetch : %+v", failed, fs)
+			}
+			t.Logf("\t%s\tShould record the failed fetch.", succeed)
+
+			if fs.Items != 0 || fs.CacheAge != 0 {
+				t.Fatalf("\t%s\tShould have nothing cached : %d, %v", failed, fs.Items, fs.CacheAge)
+			}
+			t.Logf("\t%s\tShould have nothing cached.", succeed)
+		}
+
+		t.Log("\tWhen the feed fetches after failing.")
+		{
+			if _, err := fetchDocument("TEST", srv.URL); err != nil {
+				t.Fatalf("\t%s\tShould fetch the feed : %v", failed, err)
+			}
+			time.Sleep(latency)
+
+			fs := feedStatus(t, srv.URL)
+			if fs.Fetches != 2 || fs.StatusCode != http.StatusOK || fs.Error != "" || fs.Items != 2 {
+				t.Fatalf("\t%s\tShould clear the error and record 2 items : %+v", failed, fs)
+			}
+			t.Logf("\t%s\tShould clear the error and record 2 items.", succeed)
+
+			if fs.AvgLatency < latency {
+				t.Fatalf("\t%s\tShould average at least %v per fetch : %v", failed, latency, fs.AvgLatency)
+			}
+			t.Logf("\t%s\tShould average at least %v per fetch.", succeed, latency)
+
+			if fs.CacheAge < latency {
+				t.Fatalf("\t%s\tShould age the cached document : %v", failed, fs.CacheAge)
+			}
+			t.Logf("\t%s\tShould age the cached document.", succeed)
+		}
+	}
+}
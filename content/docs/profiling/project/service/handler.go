@@ -4,10 +4,12 @@
 package service
 
 import (
+	"encoding/json"
 	"expvar"
 	"fmt"
 	"html/template"
 	"net/http"
+	"time"
 
 	"github.com/cedrickchee/ultimate-go/profiling/project/search"
 	"github.com/pborman/uuid"
@@ -18,8 +20,14 @@ var req = expvar.NewInt("requests")
 
 // handler handles the search route processing.
 func handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	uid := uuid.New()
 
+	// Report how long the search took to the snapshot watcher.
+	defer func() {
+		watcher.Observe(time.Since(start))
+	}()
+
 	// Add a new counter for monitoring.
 	req.Add(1)
 
@@ -27,18 +35,37 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	fv, options := formValues(r)
 
 	// If this is a post, perform a search.
-	var results []search.Result
+	var resp *search.Response
 	if r.Method == "POST" && options.Term != "" {
-		results = search.Submit(uid, options)
+		res := search.Submit(uid, options)
+		resp = &res
 	}
 
 	// Render the search page.
-	markup := render(fv, results)
+	markup := render(fv, resp)
 
 	// Write the final markup as the response.
 	fmt.Fprint(w, string(markup))
 }
 
+// statusHandler renders the health of every feed.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	vars := map[string]interface{}{"Feeds": search.Feeds()}
+	markup := executeTemplate("status", vars)
+
+	// Generate the final markup with the layout template.
+	vars = map[string]interface{}{"LayoutContent": template.HTML(string(markup))}
+	w.Write(executeTemplate("layout", vars))
+}
+
+// statusJSONHandler returns the health of every feed as JSON.
+func statusJSONHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(search.Feeds()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // formValues extracts the form data.
 func formValues(r *http.Request) (map[string]interface{}, search.Options) {
 	fv := make(map[string]interface{})
@@ -75,15 +102,23 @@ func formValues(r *http.Request) (map[string]interface{}, search.Options) {
 		fv["first"] = ""
 	}
 
+	if r.FormValue("wholeword") == "on" {
+		fv["wholeword"] = "checked"
+		options.WholeWord = true
+	} else {
+		fv["wholeword"] = ""
+	}
+
 	return fv, options
 }
 
 // render generates the HTML response for this route.
-func render(fv map[string]interface{}, results []search.Result) []byte {
+func render(fv map[string]interface{}, resp *search.Response) []byte {
 
-	// Generate the markup for the results template.
-	if results != nil {
-		vars := map[string]interface{}{"Items": results}
+	// Generate the markup for the results template. Sources that failed
+	// are listed in a warning above whatever results we did find.
+	if resp != nil {
+		vars := map[string]interface{}{"Items": resp.Results, "Errors": resp.Errors}
 		markup := executeTemplate("results", vars)
 		fv["Results"] = template.HTML(string(markup))
 	}
@@ -12,8 +12,22 @@ import (
 	"time"
 
 	"github.com/braintree/manners"
+	"github.com/cedrickchee/ultimate-go/profiling/project/snapshot"
 )
 
+// watcher captures profiling snapshots when the service breaches its
+// latency, goroutine or heap objectives.
+var watcher = snapshot.New(snapshot.Config{
+	Dir:        "snapshots",
+	Keep:       10,
+	Interval:   time.Second,
+	Cooldown:   time.Minute,
+	CPUFor:     5 * time.Second,
+	Latency:    2 * time.Second,
+	Goroutines: 10000,
+	HeapBytes:  512 << 20,
+})
+
 // init binds the routes and handlers for the web service.
 func init() {
 
@@ -27,6 +41,13 @@ func init() {
 
 	// Setup a route for the home page.
 	http.HandleFunc("/search", handler)
+
+	// Setup the routes for the feed health dashboard.
+	http.HandleFunc("/status", statusHandler)
+	http.HandleFunc("/status.json", statusJSONHandler)
+
+	// Setup a route to list the profiling snapshots.
+	http.Handle("/debug/snapshots/", watcher.Handler("/debug/snapshots/"))
 }
 
 // Run binds the service to a port and starts listening for requests.
@@ -44,6 +65,11 @@ func Run() {
 		MaxHeaderBytes: 1 << 20,
 	})
 
+	// Start watching for breaches of our objectives.
+	if err := watcher.Start(); err != nil {
+		log.Println("ERROR: starting snapshot watcher:", err)
+	}
+
 	// Support for shutting down cleanly.
 	go func() {
 
@@ -55,6 +81,7 @@ func Run() {
 		// We have been asked to shutdown the server.
 		log.Println("Starting shutdown...")
 		s.Close()
+		watcher.Stop()
 
 		// For now until I deal with manners handling static files.
 		go func() {
@@ -24,6 +24,7 @@ func init() {
 	loadTemplate("layout", pwd+"/views/basic-layout.html")
 	loadTemplate("search", pwd+"/views/search.html")
 	loadTemplate("results", pwd+"/views/results.html")
+	loadTemplate("status", pwd+"/views/status.html")
 }
 
 // loadTemplate reads the specified template file for use.
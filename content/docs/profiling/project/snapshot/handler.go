@@ -0,0 +1,53 @@
+// Copyright 2014 Ardan Studios
+//
+
+package snapshot
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// page renders the list of snapshots with links to their files.
+var page = template.Must(template.New("snapshots").Parse(`<html>
+<head>
+<title>Profile Snapshots</title>
+</head>
+<body>
+<h1>Profile Snapshots</h1>
+{{if not .}}<p>No snapshots have been taken.</p>{{end}}
+<table>
+{{range .}}
+<tr>
+	<td>{{.Time.Format "2006-01-02 15:04:05.000"}}</td>
+	<td>{{.Reason}}</td>
+	<td>{{$name := .Name}}{{range .Files}}<a href="{{$name}}/{{.}}">{{.}}</a> {{end}}</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+// Handler returns a handler that lists the snapshots at the prefix and
+// serves the snapshot files underneath it.
+func (w *Watcher) Handler(prefix string) http.Handler {
+	files := http.StripPrefix(prefix, http.FileServer(http.Dir(w.cfg.Dir)))
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, prefix) != "" {
+			files.ServeHTTP(rw, r)
+			return
+		}
+
+		snaps, err := w.Snapshots()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.Execute(rw, snaps)
+	})
+}
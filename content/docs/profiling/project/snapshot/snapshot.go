@@ -0,0 +1,292 @@
+// Copyright 2014 Ardan Studios
+//
+
+// Package snapshot provides support for capturing profiles of the running
+// program when it breaches its service level objectives. A Watcher checks
+// the request latency, goroutine count and heap size on an interval and
+// writes heap, goroutine and cpu profiles plus an execution trace to disk
+// when any of them crosses its threshold.
+package snapshot
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config provides the settings for a Watcher. A zero threshold disables
+// that check.
+type Config struct {
+	Dir        string        // Directory the snapshots are written to.
+	Keep       int           // Maximum number of snapshots kept on disk.
+	Interval   time.Duration // How often the thresholds are checked.
+	Cooldown   time.Duration // Minimum time between two snapshots.
+	CPUFor     time.Duration // How long the cpu profile and trace run.
+	Latency    time.Duration // Average request latency threshold.
+	Goroutines int           // Goroutine count threshold.
+	HeapBytes  uint64        // Heap in use threshold.
+}
+
+// Snapshot represents a set of profiles captured on disk.
+type Snapshot struct {
+	Name   string
+	Time   time.Time
+	Reason string
+	Files  []string
+}
+
+// timeFormat is used to name the snapshot directories so they sort
+// in the order they were taken.
+const timeFormat = "20060102T150405.000"
+
+// Watcher checks the thresholds and captures the snapshots.
+type Watcher struct {
+	cfg      Config
+	total    int64 // Sum of request latencies in the current interval.
+	requests int64 // Number of requests in the current interval.
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+	last     time.Time
+}
+
+// New returns a Watcher for the specified configuration.
+func New(cfg Config) *Watcher {
+	if cfg.Keep <= 0 {
+		cfg.Keep = 10
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.CPUFor <= 0 {
+		cfg.CPUFor = 5 * time.Second
+	}
+
+	return &Watcher{
+		cfg:      cfg,
+		shutdown: make(chan struct{}),
+	}
+}
+
+// Observe records the latency of a request. It is safe to call from
+// any number of goroutines.
+func (w *Watcher) Observe(d time.Duration) {
+	atomic.AddInt64(&w.total, int64(d))
+	atomic.AddInt64(&w.requests, 1)
+}
+
+// Start begins checking the thresholds on the configured interval.
+func (w *Watcher) Start() error {
+	if err := os.MkdirAll(w.cfg.Dir, 0755); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		t := time.NewTicker(w.cfg.Interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				if reasons := w.check(); len(reasons) > 0 {
+					w.capture(reasons)
+				}
+
+			case <-w.shutdown:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop terminates the checks and waits for any snapshot in progress.
+func (w *Watcher) Stop() {
+	close(w.shutdown)
+	w.wg.Wait()
+}
+
+// check returns the thresholds that have been crossed since the last check.
+func (w *Watcher) check() []string {
+	var reasons []string
+
+	total := atomic.SwapInt64(&w.total, 0)
+	requests := atomic.SwapInt64(&w.requests, 0)
+	if w.cfg.Latency > 0 && requests > 0 {
+		if avg := time.Duration(total / requests); avg > w.cfg.Latency {
+			reasons = append(reasons, "latency")
+		}
+	}
+
+	if w.cfg.Goroutines > 0 && runtime.NumGoroutine() > w.cfg.Goroutines {
+		reasons = append(reasons, "goroutines")
+	}
+
+	if w.cfg.HeapBytes > 0 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		if ms.HeapInuse > w.cfg.HeapBytes {
+			reasons = append(reasons, "heap")
+		}
+	}
+
+	return reasons
+}
+
+// capture writes a new snapshot to disk unless one was taken within the
+// cooldown period, then removes the oldest snapshots over the limit.
+func (w *Watcher) capture(reasons []string) {
+	now := time.Now()
+	if !w.last.IsZero() && now.Sub(w.last) < w.cfg.Cooldown {
+		return
+	}
+	w.last = now
+
+	name := now.Format(timeFormat) + "-" + strings.Join(reasons, "_")
+	dir := filepath.Join(w.cfg.Dir, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		log.Println("ERROR: snapshot:", err)
+		return
+	}
+
+	log.Println("snapshot: capturing", dir)
+
+	// Take the heap and goroutines as they are right now.
+	for _, p := range []string{"heap", "goroutine"} {
+		if err := writeProfile(filepath.Join(dir, p+".pprof"), p); err != nil {
+			log.Println("ERROR: snapshot:", p, err)
+		}
+	}
+
+	// The cpu profile and trace need to run for a while.
+	if err := writeTimed(dir, w.cfg.CPUFor); err != nil {
+		log.Println("ERROR: snapshot:", err)
+	}
+
+	w.rotate()
+}
+
+// writeProfile writes the named runtime profile to the file.
+func writeProfile(file string, name string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return pprof.Lookup(name).WriteTo(f, 0)
+}
+
+// writeTimed runs the cpu profile and the execution trace together for
+// the specified duration. Either can fail if someone else is already
+// running one through the pprof endpoints.
+func writeTimed(dir string, d time.Duration) error {
+	cpu, err := os.Create(filepath.Join(dir, "cpu.pprof"))
+	if err != nil {
+		return err
+	}
+	defer cpu.Close()
+
+	tr, err := os.Create(filepath.Join(dir, "trace.out"))
+	if err != nil {
+		return err
+	}
+	defer tr.Close()
+
+	var errs []string
+
+	cpuErr := pprof.StartCPUProfile(cpu)
+	if cpuErr != nil {
+		errs = append(errs, "cpu: "+cpuErr.Error())
+	}
+
+	trErr := trace.Start(tr)
+	if trErr != nil {
+		errs = append(errs, "trace: "+trErr.Error())
+	}
+
+	time.Sleep(d)
+
+	if cpuErr == nil {
+		pprof.StopCPUProfile()
+	}
+	if trErr == nil {
+		trace.Stop()
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// rotate removes the oldest snapshots until we are within the limit.
+func (w *Watcher) rotate() {
+	snaps, err := w.Snapshots()
+	if err != nil {
+		log.Println("ERROR: snapshot:", err)
+		return
+	}
+
+	for i := w.cfg.Keep; i < len(snaps); i++ {
+		if err := os.RemoveAll(filepath.Join(w.cfg.Dir, snaps[i].Name)); err != nil {
+			log.Println("ERROR: snapshot:", err)
+		}
+	}
+}
+
+// Snapshots returns the snapshots on disk, newest first. A snapshot that
+// is still being captured is listed with the files written so far.
+func (w *Watcher) Snapshots() ([]Snapshot, error) {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var snaps []Snapshot
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		i := strings.Index(e.Name(), "-")
+		if i == -1 {
+			continue
+		}
+
+		t, err := time.ParseInLocation(timeFormat, e.Name()[:i], time.Local)
+		if err != nil {
+			continue
+		}
+
+		s := Snapshot{
+			Name:   e.Name(),
+			Time:   t,
+			Reason: strings.ReplaceAll(e.Name()[i+1:], "_", ", "),
+		}
+
+		files, _ := os.ReadDir(filepath.Join(w.cfg.Dir, e.Name()))
+		for _, f := range files {
+			s.Files = append(s.Files, f.Name())
+		}
+
+		snaps = append(snaps, s)
+	}
+
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Name > snaps[j].Name })
+
+	return snaps, nil
+}
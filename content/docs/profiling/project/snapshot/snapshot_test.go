@@ -0,0 +1,157 @@
+// Copyright 2014 Ardan Studios
+//
+// All material is licensed under the Apache License Version 2.0, January 2004
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const succeed = "✓"
+const failed = "✗"
+
+// mkdirs creates the directories, and a file inside each one, under dir.
+func mkdirs(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "heap.pprof"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// names returns the names of the snapshots in order.
+func names(snaps []Snapshot) []string {
+	var s []string
+	for _, snap := range snaps {
+		s = append(s, snap.Name)
+	}
+	return s
+}
+
+// TestSnapshots validates the snapshot directories are parsed and listed
+// newest first, skipping anything that isn't a snapshot.
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir,
+		"20240101T100000.000-latency",
+		"20240101T120000.500-latency_heap",
+		"20240101T110000.000-goroutines",
+		"not-a-snapshot",
+		"nodash",
+	)
+	os.WriteFile(filepath.Join(dir, "20240101T130000.000-heap"), nil, 0644)
+
+	t.Log("Given the need to list the snapshots on disk.")
+	{
+		t.Log("\tWhen the directory has snapshots, other directories and a file.")
+		{
+			w := New(Config{Dir: dir})
+			snaps, err := w.Snapshots()
+			if err != nil {
+				t.Fatalf("\t%s\tShould list the snapshots : %v", failed, err)
+			}
+
+			want := []string{
+				"20240101T120000.500-latency_heap",
+				"20240101T110000.000-goroutines",
+				"20240101T100000.000-latency",
+			}
+			if got := names(snaps); !reflect.DeepEqual(got, want) {
+				t.Fatalf("\t%s\tShould list only the snapshots, newest first : %v", failed, got)
+			}
+			t.Logf("\t%s\tShould list only the snapshots, newest first.", succeed)
+
+			s := snaps[0]
+			at := time.Date(2024, 1, 1, 12, 0, 0, 500*int(time.Millisecond), time.Local)
+			if !s.Time.Equal(at) || s.Reason != "latency, heap" || !reflect.DeepEqual(s.Files, []string{"heap.pprof"}) {
+				t.Fatalf("\t%s\tShould parse the time, reasons and files : %+v", failed, s)
+			}
+			t.Logf("\t%s\tShould parse the time, reasons and files.", succeed)
+		}
+	}
+}
+
+// TestRotate validates only the newest Keep snapshots are kept.
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir,
+		"20240101T100000.000-latency",
+		"20240101T110000.000-heap",
+		"20240101T120000.000-goroutines",
+		"20240101T130000.000-latency",
+		"not-a-snapshot",
+	)
+
+	t.Log("Given the need to limit the snapshots kept on disk.")
+	{
+		t.Log("\tWhen there are 4 snapshots and we keep 2.")
+		{
+			w := New(Config{Dir: dir, Keep: 2})
+			w.rotate()
+
+			snaps, _ := w.Snapshots()
+			want := []string{"20240101T130000.000-latency", "20240101T120000.000-goroutines"}
+			if got := names(snaps); !reflect.DeepEqual(got, want) {
+				t.Fatalf("\t%s\tShould keep the 2 newest : %v", failed, got)
+			}
+			t.Logf("\t%s\tShould keep the 2 newest.", succeed)
+
+			if _, err := os.Stat(filepath.Join(dir, "not-a-snapshot")); err != nil {
+				t.Fatalf("\t%s\tShould leave other directories alone : %v", failed, err)
+			}
+			t.Logf("\t%s\tShould leave other directories alone.", succeed)
+		}
+	}
+}
+
+// TestCapture validates a snapshot has every profile and no other snapshot
+// is taken within the cooldown.
+func TestCapture(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Log("Given the need to capture snapshots.")
+	{
+		t.Log("\tWhen capturing twice within the cooldown.")
+		{
+			w := New(Config{Dir: dir, Cooldown: time.Hour, CPUFor: 10 * time.Millisecond})
+			w.capture([]string{"latency", "heap"})
+			w.capture([]string{"goroutines"})
+
+			snaps, _ := w.Snapshots()
+			if len(snaps) != 1 || snaps[0].Reason != "latency, heap" {
+				t.Fatalf("\t%s\tShould take only the first snapshot : %v", failed, names(snaps))
+			}
+			t.Logf("\t%s\tShould take only the first snapshot.", succeed)
+
+			want := []string{"cpu.pprof", "goroutine.pprof", "heap.pprof", "trace.out"}
+			if !reflect.DeepEqual(snaps[0].Files, want) {
+				t.Fatalf("\t%s\tShould write every profile : %v", failed, snaps[0].Files)
+			}
+			t.Logf("\t%s\tShould write every profile.", succeed)
+		}
+
+		t.Log("\tWhen capturing again once the cooldown is over.")
+		{
+			w := New(Config{Dir: dir, Cooldown: time.Hour, CPUFor: 10 * time.Millisecond})
+			w.last = time.Now().Add(-2 * time.Hour)
+			w.capture([]string{"goroutines"})
+
+			snaps, _ := w.Snapshots()
+			if len(snaps) != 2 || snaps[0].Reason != "goroutines" {
+				t.Fatalf("\t%s\tShould take another snapshot : %v", failed, names(snaps))
+			}
+			t.Logf("\t%s\tShould take another snapshot.", succeed)
+		}
+	}
+}